@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"pushbulleter/internal/app"
+	"pushbulleter/internal/config"
+	"pushbulleter/internal/pushbullet"
+	"pushbulleter/internal/state"
+)
+
+// runDevices lists, renames or deletes the devices on the account
+func runDevices(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("devices", flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintln(out, "Usage: pushbulleter devices [flags]")
+		fmt.Fprintln(out, "       pushbulleter devices rename IDEN NICKNAME")
+		fmt.Fprintln(out, "       pushbulleter devices delete IDEN")
+		fs.PrintDefaults()
+	}
+	asJSON := fs.Bool("json", false, "Print devices as JSON")
+	fs.Parse(args)
+
+	if cfg.APIKey == "" {
+		return fmt.Errorf("api_key is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := app.NewClient(cfg)
+
+	switch fs.Arg(0) {
+	case "":
+	case "rename":
+		if fs.NArg() < 3 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		nickname := strings.Join(fs.Args()[2:], " ")
+		_, err := client.UpdateDevice(ctx, fs.Arg(1), &pushbullet.DeviceParams{Nickname: nickname})
+		return err
+	case "delete":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		return client.DeleteDevice(ctx, fs.Arg(1))
+	default:
+		return fmt.Errorf("unknown devices command %q", fs.Arg(0))
+	}
+
+	devices, err := client.ListDevices(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(devices)
+	}
+
+	this := thisDevice()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IDEN\tNICKNAME\tMODEL\t")
+	for _, device := range devices {
+		nickname := device.Nickname
+		if device.Iden == this {
+			nickname += " (this desktop)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", device.Iden, nickname, strings.TrimSpace(device.Manufacturer+" "+device.Model))
+	}
+	return w.Flush()
+}
+
+// thisDevice returns the iden of the device pushbulleter registered for this
+// desktop, or "" before the first run
+func thisDevice() string {
+	st, err := state.Load("")
+	if err != nil {
+		return ""
+	}
+	return st.DeviceIden
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"pushbulleter/internal/config"
+	"pushbulleter/internal/history"
+)
+
+// runHistory prints recorded events matching the command line filters
+func runHistory(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pushbulleter history [flags] [text]")
+		fs.PrintDefaults()
+	}
+
+	var (
+		since    = fs.String("since", "", "Only show events after this time (duration such as 24h, or YYYY-MM-DD)")
+		until    = fs.String("until", "", "Only show events before this time (duration such as 1h, or YYYY-MM-DD)")
+		types    = fs.String("type", "", "Comma-separated event types, e.g. mirror,sms_changed,note")
+		appName  = fs.String("app", "", "Only show events from apps whose name contains this")
+		limit    = fs.Int("limit", 50, "Maximum number of events to show, newest kept (0 for all)")
+		jsonLine = fs.Bool("json", false, "Print events as JSON lines")
+	)
+	fs.Parse(args)
+
+	q := history.Query{
+		App:   *appName,
+		Text:  strings.Join(fs.Args(), " "),
+		Limit: *limit,
+	}
+
+	var err error
+	if q.Since, err = parseHistoryTime(*since); err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	if q.Until, err = parseHistoryTime(*until); err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+	if *types != "" {
+		q.Types = strings.Split(*types, ",")
+	}
+
+	// Leave compaction to the running daemon
+	store := history.OpenReadOnly("", cfg.History.MaxAge)
+
+	events, err := store.Query(q)
+	if err != nil {
+		return err
+	}
+
+	if *jsonLine {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(events) == 0 {
+		fmt.Println("No events")
+		return nil
+	}
+
+	for _, event := range events {
+		source := event.Type
+		if event.App != "" {
+			source = fmt.Sprintf("%s/%s", event.Type, event.App)
+		}
+
+		text := event.Title
+		if event.Message != "" {
+			if text != "" {
+				text += ": "
+			}
+			text += event.Message
+		}
+		if event.URL != "" {
+			text += " <" + event.URL + ">"
+		}
+
+		fmt.Printf("%s  %-24s  %s\n", event.Timestamp.Local().Format("2006-01-02 15:04"), source, strings.ReplaceAll(text, "\n", " "))
+	}
+
+	return nil
+}
+
+// parseHistoryTime accepts a duration before now or a date or RFC 3339 time
+func parseHistoryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a duration, YYYY-MM-DD or RFC 3339 time, got %q", value)
+	}
+	return t, nil
+}
@@ -15,6 +15,7 @@ import (
 func main() {
 	var (
 		configPath = flag.String("config", "", "Path to config file (default: XDG_CONFIG_HOME/pushbulleter/config.yaml)")
+		testRule   = flag.String("test-rule", "", "Show how notification rules handle a sample push JSON file (- for stdin) and exit")
 	)
 	flag.Parse()
 
@@ -24,6 +25,39 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	switch flag.Arg(0) {
+	case "history":
+		if err := runHistory(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to read history: %v", err)
+		}
+		return
+	case "push":
+		if err := runPush(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to push: %v", err)
+		}
+		return
+	case "devices":
+		if err := runDevices(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to manage devices: %v", err)
+		}
+		return
+	case "whoami":
+		if err := runWhoami(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to get user: %v", err)
+		}
+		return
+	case "":
+	default:
+		log.Fatalf("Unknown command %q", flag.Arg(0))
+	}
+
+	if *testRule != "" {
+		if err := runTestRule(cfg, *testRule); err != nil {
+			log.Fatalf("Failed to test rules: %v", err)
+		}
+		return
+	}
+
 	// Create application
 	application, err := app.New(cfg)
 	if err != nil {
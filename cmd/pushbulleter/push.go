@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pushbulleter/internal/app"
+	"pushbulleter/internal/config"
+	"pushbulleter/internal/pushbullet"
+)
+
+const pushUsage = `Usage: pushbulleter push note [flags]
+       pushbulleter push link -url URL [flags]
+       pushbulleter push file [flags] PATH
+
+-body - reads the body from stdin, and a PATH of - uploads stdin.`
+
+// runPush sends a note, link or file push from the command line
+func runPush(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, pushUsage)
+		os.Exit(2)
+	}
+	kind := args[0]
+
+	fs := flag.NewFlagSet("push "+kind, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), pushUsage)
+		fs.PrintDefaults()
+	}
+
+	var (
+		title    = fs.String("title", "", "Title of the push")
+		body     = fs.String("body", "", "Body of the push (- for stdin)")
+		device   = fs.String("device", "", "Send to the device with this iden or nickname")
+		email    = fs.String("email", "", "Send to this email address")
+		channel  = fs.String("channel", "", "Send to the subscribers of this channel tag")
+		asJSON   = fs.Bool("json", false, "Print the created push as JSON")
+		linkURL  *string
+		name     *string
+		fileType *string
+	)
+	switch kind {
+	case "note":
+	case "link":
+		linkURL = fs.String("url", "", "URL of the link")
+	case "file":
+		name = fs.String("name", "", "File name shown to recipients (default: base name of PATH)")
+		fileType = fs.String("type", "", "MIME type of the file (default: detected)")
+	default:
+		return fmt.Errorf("unknown push type %q, want note, link or file", kind)
+	}
+	fs.Parse(args[1:])
+
+	if cfg.APIKey == "" {
+		return fmt.Errorf("api_key is not set")
+	}
+
+	switch kind {
+	case "note", "link":
+		if fs.NArg() > 0 {
+			return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+		}
+		if kind == "link" && *linkURL == "" {
+			return fmt.Errorf("-url is required")
+		}
+	case "file":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		if fs.Arg(0) == "-" && *body == "-" {
+			return fmt.Errorf("-body - cannot be used when uploading stdin")
+		}
+	}
+
+	if *body == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		*body = strings.TrimRight(string(data), "\n")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := app.NewClient(cfg)
+
+	var req *pushbullet.CreatePushRequest
+	switch kind {
+	case "note":
+		req = pushbullet.NewNote(*title, *body)
+	case "link":
+		req = pushbullet.NewLink(*title, *body, *linkURL)
+	case "file":
+		upload, err := uploadFile(ctx, client, fs.Arg(0), *name, *fileType)
+		if err != nil {
+			return err
+		}
+		req = pushbullet.NewFile(upload.FileName, upload.FileType, upload.FileURL, *body)
+		req.Title = *title
+	}
+
+	target, err := pushTarget(ctx, client, *device, *email, *channel)
+	if err != nil {
+		return err
+	}
+	req.PushTarget = target
+	req.SourceDeviceIden = thisDevice()
+
+	apiCtx, apiCancel := context.WithTimeout(ctx, 30*time.Second)
+	defer apiCancel()
+
+	push, err := client.CreatePush(apiCtx, req)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(push)
+	}
+
+	fmt.Printf("Pushed %s %s\n", push.Type, push.Iden)
+	return nil
+}
+
+// pushTarget resolves the recipient flags, of which at most one may be set
+func pushTarget(ctx context.Context, client *pushbullet.Client, device, email, channel string) (pushbullet.PushTarget, error) {
+	set := 0
+	for _, value := range []string{device, email, channel} {
+		if value != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return pushbullet.PushTarget{}, fmt.Errorf("only one of -device, -email and -channel can be used")
+	}
+
+	if device == "" {
+		return pushbullet.PushTarget{Email: email, ChannelTag: channel}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	devices, err := client.ListDevices(ctx)
+	if err != nil {
+		return pushbullet.PushTarget{}, err
+	}
+	for _, d := range devices {
+		if d.Iden == device || strings.EqualFold(d.Nickname, device) {
+			return pushbullet.PushTarget{DeviceIden: d.Iden}, nil
+		}
+	}
+
+	return pushbullet.PushTarget{}, fmt.Errorf("no device with iden or nickname %q", device)
+}
+
+// uploadFile uploads the file at path, or stdin for -, guessing its MIME type
+// from the name or contents unless fileType is given
+func uploadFile(ctx context.Context, client *pushbullet.Client, path, name, fileType string) (*pushbullet.Upload, error) {
+	var file *os.File
+	if path == "-" {
+		file = os.Stdin
+		if name == "" {
+			name = "stdin"
+		}
+	} else {
+		var err error
+		if file, err = os.Open(path); err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		if name == "" {
+			name = filepath.Base(path)
+		}
+	}
+
+	var r io.Reader = file
+	if fileType == "" {
+		fileType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if fileType == "" {
+		// Sniff the start of the file, then send it along with the rest
+		head := make([]byte, 512)
+		n, err := io.ReadFull(file, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		fileType = http.DetectContentType(head[:n])
+		r = io.MultiReader(bytes.NewReader(head[:n]), file)
+	}
+
+	return client.UploadFile(ctx, name, fileType, r)
+}
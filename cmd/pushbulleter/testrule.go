@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"pushbulleter/internal/app"
+	"pushbulleter/internal/config"
+)
+
+// runTestRule prints how the configured rules handle a sample push
+func runTestRule(cfg *config.Config, samplePath string) error {
+	var data []byte
+	var err error
+	if samplePath == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(samplePath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read sample: %w", err)
+	}
+
+	result, err := app.TestRule(cfg, data)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Rule == "":
+		fmt.Println("No rule matched")
+	case result.Drop:
+		fmt.Printf("Matched rule %q: drop\n", result.Rule)
+	default:
+		fmt.Printf("Matched rule %q: allow\n", result.Rule)
+	}
+
+	if len(result.Notifications) == 0 {
+		fmt.Println("No notification would be shown")
+		return nil
+	}
+
+	for _, n := range result.Notifications {
+		fmt.Println()
+		fmt.Printf("Title:    %s\n", n.Title)
+		fmt.Printf("Body:     %s\n", n.Body)
+		fmt.Printf("Urgency:  %s\n", n.Urgency)
+		fmt.Printf("Timeout:  %s\n", n.Timeout)
+		if n.ImagePath != "" {
+			fmt.Printf("Icon:     %s\n", n.ImagePath)
+		} else {
+			fmt.Printf("Icon:     %s\n", n.Icon)
+		}
+		for _, action := range n.Actions {
+			fmt.Printf("Action:   %s\n", action.Label)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"pushbulleter/internal/app"
+	"pushbulleter/internal/config"
+)
+
+// runWhoami prints the account the API key belongs to
+func runWhoami(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("whoami", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pushbulleter whoami [flags]")
+		fs.PrintDefaults()
+	}
+	asJSON := fs.Bool("json", false, "Print the user as JSON")
+	fs.Parse(args)
+
+	if cfg.APIKey == "" {
+		return fmt.Errorf("api_key is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, err := app.NewClient(cfg).GetUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(user)
+	}
+
+	fmt.Printf("Iden:            %s\n", user.Iden)
+	fmt.Printf("Email:           %s\n", user.Email)
+	fmt.Printf("Name:            %s\n", user.Name)
+	if user.ImageURL != "" {
+		fmt.Printf("Image:           %s\n", user.ImageURL)
+	}
+	fmt.Printf("Max upload size: %.0f MB\n", user.MaxUploadSize/(1<<20))
+	fmt.Printf("Created:         %s\n", unixTime(user.Created).Format(time.RFC3339))
+	fmt.Printf("Modified:        %s\n", unixTime(user.Modified).Format(time.RFC3339))
+
+	return nil
+}
+
+// unixTime converts a Pushbullet timestamp in fractional seconds
+func unixTime(ts float64) time.Time {
+	return time.Unix(0, int64(ts*float64(time.Second)))
+}
@@ -2,15 +2,20 @@ package app
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"pushbulleter/internal/config"
+	"pushbulleter/internal/history"
 	"pushbulleter/internal/notifications"
 	"pushbulleter/internal/pushbullet"
+	"pushbulleter/internal/state"
+	"pushbulleter/internal/sysevents"
 	"pushbulleter/internal/tray"
 )
 
@@ -19,6 +24,17 @@ type App struct {
 	client       *pushbullet.Client
 	notifManager *notifications.Manager
 	trayManager  *tray.TrayManager
+	history      *history.Store
+	sysWatcher   sysevents.Watcher
+
+	stateMu     sync.Mutex
+	state       *state.State
+	resumeTimer *time.Timer
+	pauseGen    uint64
+
+	syncMu       sync.Mutex
+	lastModified float64
+	syncCh       chan struct{}
 }
 
 func New(cfg *config.Config) (*App, error) {
@@ -26,37 +42,142 @@ func New(cfg *config.Config) (*App, error) {
 		return nil, fmt.Errorf("API key is required. Please set it in the config file")
 	}
 
+	client := NewClient(cfg)
+
+	notifier, err := notifications.NewNotifier(cfg.Notifications.Backend, cfg.Notifications.ExecCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up notifications: %w", err)
+	}
+
+	notifManager, err := newNotificationManager(cfg, notifier)
+	if err != nil {
+		return nil, err
+	}
+	notifManager.SetResponder(client)
+
+	st, err := state.Load("")
+	if err != nil {
+		log.Printf("Failed to load state, starting fresh: %v", err)
+		st = &state.State{}
+	}
+
+	var events *history.Store
+	if cfg.History.Enabled {
+		events, err = history.Open("", cfg.History.MaxEntries, cfg.History.MaxAge)
+		if err != nil {
+			log.Printf("Failed to open event history: %v", err)
+		}
+	}
+
+	app := &App{
+		config:       cfg,
+		state:        st,
+		client:       client,
+		notifManager: notifManager,
+		trayManager:  tray.NewTrayManager(),
+		history:      events,
+		syncCh:       make(chan struct{}, 1),
+	}
+
+	// Derive the E2E key without waiting for the API
+	if st.UserIden != "" {
+		app.useUserIden(st.UserIden)
+	}
+	notifManager.SetDeviceIden(st.DeviceIden)
+
+	return app, nil
+}
+
+// NewClient creates a Pushbullet client with the endpoints and E2E
+// encryption from the config.
+func NewClient(cfg *config.Config) *pushbullet.Client {
 	var e2eKey string
 	if cfg.E2EEnabled {
 		e2eKey = cfg.E2EKey
 	}
 
-	client := pushbullet.NewClient(cfg.APIKey, e2eKey)
+	return pushbullet.NewClient(cfg.APIKey, e2eKey, clientOptions(cfg)...)
+}
+
+// clientOptions returns the Pushbullet endpoints from the environment or the
+// config file.
+func clientOptions(cfg *config.Config) []pushbullet.Option {
+	var opts []pushbullet.Option
+
+	if apiURL := firstNonEmpty(os.Getenv("PUSHBULLETER_API_URL"), cfg.APIURL); apiURL != "" {
+		opts = append(opts, pushbullet.WithAPIBase(apiURL))
+	}
+	if streamURL := firstNonEmpty(os.Getenv("PUSHBULLETER_STREAM_URL"), cfg.StreamURL); streamURL != "" {
+		opts = append(opts, pushbullet.WithStreamURL(streamURL))
+	}
+
+	return opts
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func newNotificationManager(cfg *config.Config, notifier notifications.Notifier) (*notifications.Manager, error) {
+	rules, err := notifications.CompileRules(cfg.Notifications.Rules)
+	if err != nil {
+		return nil, err
+	}
 
 	notifManager := notifications.NewManager(
+		notifier,
 		cfg.Notifications.Enabled,
 		cfg.Notifications.ShowMirrors,
 		cfg.Notifications.ShowSMS,
 		cfg.Notifications.ShowCalls,
-		cfg.Notifications.Filters,
 	)
+	// Legacy filters come first so that no rule shows what they hide
+	notifManager.SetRules(append(notifications.LegacyFilterRules(cfg.Notifications.Filters), rules...))
 
-	app := &App{
-		config:       cfg,
-		client:       client,
-		notifManager: notifManager,
-		trayManager:  tray.NewTrayManager(),
+	quietHours, err := notifications.NewQuietHours(cfg.Notifications.QuietHours)
+	if err != nil {
+		return nil, err
 	}
+	notifManager.SetQuietHours(quietHours)
 
-	return app, nil
+	return notifManager, nil
+}
+
+// TestRule reports how the configured notification rules handle a sample
+// push or ephemeral in JSON form, without showing anything.
+func TestRule(cfg *config.Config, data []byte) (*notifications.DryRunResult, error) {
+	notifManager, err := newNotificationManager(cfg, notifications.NewLogNotifier())
+	if err != nil {
+		return nil, err
+	}
+
+	return notifManager.DryRun(data)
 }
 
 func (a *App) RunGUI(ctx context.Context) error {
 	log.Println("Starting pushbulleter...")
 
-	// Test API connection
+	// Test API connection. Once the account is known from an earlier run,
+	// start anyway and let the stream keep retrying.
 	if err := a.testConnection(ctx); err != nil {
-		return fmt.Errorf("failed to connect to Pushbullet API: %w", err)
+		a.stateMu.Lock()
+		cached := a.state.UserIden != ""
+		a.stateMu.Unlock()
+
+		if !cached || errors.Is(err, pushbullet.ErrUnauthorized) {
+			return fmt.Errorf("failed to connect to Pushbullet API: %w", err)
+		}
+		log.Printf("Failed to connect to Pushbullet API, using cached account: %v", err)
+	}
+
+	// Register this desktop so pushes can be sent to it
+	if err := a.registerDevice(ctx); err != nil {
+		log.Printf("Failed to register device: %v", err)
 	}
 
 	// Setup autostart if enabled
@@ -66,9 +187,53 @@ func (a *App) RunGUI(ctx context.Context) error {
 		}
 	}
 
+	// Fill the tray's Recent menu from the previous run
+	if a.history != nil {
+		events, err := a.history.Query(history.Query{Limit: tray.RecentLimit})
+		if err != nil {
+			log.Printf("Failed to load recent events: %v", err)
+		}
+		a.trayManager.SetRecent(events)
+	}
+
+	// Keep notifications paused across restarts
+	a.trayManager.OnPause(a.pause)
+	a.trayManager.OnResume(a.resume)
+	a.restorePause()
+
+	// Resume push tracking where the last run left off
+	if err := a.initSyncCursor(ctx); err != nil {
+		log.Printf("Failed to initialize push sync: %v", err)
+	}
+	go a.runSync(ctx)
+
+	go a.watchQuietHours(ctx)
+	go a.watchConnection(ctx)
+
+	// Reconnect right away after resume or when the network comes back
+	if a.sysWatcher == nil {
+		if watcher, err := sysevents.NewDBusWatcher(); err != nil {
+			log.Printf("Failed to watch for suspend and network changes: %v", err)
+		} else {
+			a.sysWatcher = watcher
+		}
+	}
+	if a.sysWatcher != nil {
+		go a.watchSystem(ctx, a.sysWatcher)
+	}
+	if a.config.Metrics.Listen != "" {
+		go a.serveMetrics(ctx, a.config.Metrics.Listen)
+	}
+	a.trayManager.OnReconnect(a.client.Reconnect)
+
 	// Start stream connection in background
 	go func() {
-		if err := a.client.ConnectStream(ctx, a.handleStreamMessage); err != nil {
+		err := a.client.ConnectStream(ctx, a.handleStreamMessage)
+		if errors.Is(err, pushbullet.ErrUnauthorized) {
+			a.notifManager.ShowAlert("Pushbullet API key rejected",
+				"pushbulleter stopped receiving pushes. Check api_key in the config file and restart.")
+		}
+		if err != nil {
 			log.Printf("Stream connection ended: %v", err)
 		}
 	}()
@@ -92,42 +257,125 @@ func (a *App) Stop() {
 	}
 }
 
+// watchQuietHours keeps the tray's do-not-disturb indicator current and shows
+// the notifications held back once quiet hours end.
+func (a *App) watchQuietHours(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	active := a.notifManager.QuietHoursActive()
+	a.trayManager.SetDND(active)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if now := a.notifManager.QuietHoursActive(); now != active {
+				active = now
+				a.trayManager.SetDND(active)
+				if !active {
+					a.notifManager.FlushQuietQueue()
+				}
+			}
+		}
+	}
+}
+
+// watchConnection shows the stream connection state in the tray and catches
+// up on pushes after every reconnect.
+func (a *App) watchConnection(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case status := <-a.client.ConnStates():
+			switch status.State {
+			case pushbullet.ConnConnected:
+				a.trayManager.SetConnection(true, "connected")
+				// Fetch pushes sent while the stream was down
+				a.requestSync()
+			case pushbullet.ConnBackoff:
+				a.trayManager.SetConnection(false, fmt.Sprintf("offline, retrying at %s", status.RetryAt.Format("15:04:05")))
+			case pushbullet.ConnAuthFailed:
+				a.trayManager.SetConnection(false, "API key rejected")
+			default:
+				a.trayManager.SetConnection(false, "connecting...")
+			}
+		}
+	}
+}
+
+// watchSystem reconnects the stream when the system resumes from suspend or
+// the network comes up, instead of waiting for the dead connection to time
+// out. Pushes missed meanwhile are fetched once the stream is connected.
+func (a *App) watchSystem(ctx context.Context, watcher sysevents.Watcher) {
+	defer watcher.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events():
+			if !ok {
+				return
+			}
+
+			log.Printf("System event: %s", event)
+			if event == sysevents.Resume || event == sysevents.NetworkUp {
+				a.client.Reconnect()
+			}
+		}
+	}
+}
+
 func (a *App) testConnection(ctx context.Context) error {
 	user, err := a.client.GetUser(ctx)
 	if err != nil {
 		return err
 	}
 
-	if email, ok := user["email"].(string); ok {
-		log.Printf("Connected as: %s", email)
-	} else {
-		log.Println("Connected to Pushbullet API")
-	}
+	log.Printf("Connected as: %s", user.Email)
+
+	a.useUserIden(user.Iden)
+
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
 
-	// Update E2E encryption with user iden if available
-	if a.config.E2EEnabled && a.config.E2EKey != "" {
-		if userIden, ok := user["iden"].(string); ok {
-			a.client.UpdateE2EWithUserIden(a.config.E2EKey, userIden)
-			log.Println("Updated E2E encryption with user iden")
+	if a.state.UserIden != user.Iden {
+		a.state.UserIden = user.Iden
+		if err := a.state.Save(""); err != nil {
+			log.Printf("Failed to save user iden: %v", err)
 		}
 	}
 
 	return nil
 }
 
+// useUserIden sets the account's iden, which is needed to send ephemerals and
+// salts the E2E key.
+func (a *App) useUserIden(userIden string) {
+	a.client.SetUserIden(userIden)
+
+	if a.config.E2EEnabled && a.config.E2EKey != "" && userIden != "" {
+		a.client.UpdateE2EWithUserIden(a.config.E2EKey, userIden)
+	}
+}
+
 func (a *App) handleStreamMessage(msg *pushbullet.StreamMessage) {
-	// Add to events window
-	HandleEvent(msg)
-
-	// Handle push notifications
-	if msg.Type == "push" && len(msg.Push) > 0 {
-		var push pushbullet.Push
-		if err := json.Unmarshal(msg.Push, &push); err != nil {
-			log.Printf("Failed to unmarshal push: %v", err)
-			return
-		}
+	if event := HandleEvent(msg); event != nil {
+		a.recordEvent(*event)
+	}
+
+	// A push tickle means pushes changed server-side and need to be fetched
+	if msg.Type == "tickle" && msg.Subtype == "push" {
+		a.requestSync()
+		return
+	}
 
-		a.notifManager.HandlePush(&push)
+	// Handle ephemeral notifications
+	if msg.Ephemeral != nil {
+		a.notifManager.HandleEphemeral(msg.Ephemeral)
 	}
 }
 
@@ -0,0 +1,403 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pushbulleter/internal/config"
+	"pushbulleter/internal/history"
+	"pushbulleter/internal/notifications"
+	"pushbulleter/internal/pushbullet"
+	"pushbulleter/internal/pushbullet/pbtest"
+	"pushbulleter/internal/state"
+	"pushbulleter/internal/sysevents"
+)
+
+const testAPIKey = "o.testkey"
+
+// newTestApp creates an App talking to a fake server, with its state,
+// history and notifications kept in the test.
+func newTestApp(t *testing.T, server *pbtest.Server) (*App, *notifications.Recorder) {
+	t.Helper()
+
+	cfg := &config.Config{
+		APIKey:    testAPIKey,
+		APIURL:    server.URL(),
+		StreamURL: server.StreamURL(),
+		Notifications: config.NotificationConfig{
+			Enabled:     true,
+			ShowMirrors: true,
+			ShowSMS:     true,
+			ShowCalls:   true,
+			Backend:     "log",
+		},
+		Sync:    config.SyncConfig{CatchUp: true, MaxCatchUp: 10},
+		History: config.HistoryConfig{Enabled: true},
+	}
+
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	recorder := notifications.NewRecorder()
+	a.notifManager, err = newNotificationManager(cfg, recorder)
+	if err != nil {
+		t.Fatalf("newNotificationManager: %v", err)
+	}
+
+	return a, recorder
+}
+
+func setTestDirs(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", dir+"/state")
+	t.Setenv("XDG_DATA_HOME", dir+"/data")
+	t.Setenv("XDG_CACHE_HOME", dir+"/cache")
+}
+
+func waitForShown(t *testing.T, recorder *notifications.Recorder, n int) []*notifications.Notification {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		shown := recorder.Shown()
+		if len(shown) >= n {
+			return shown
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("shown %d notifications, want %d", len(shown), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStreamToNotifications(t *testing.T) {
+	setTestDirs(t)
+	server := pbtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	server.AddPush(pushbullet.Push{Type: "note", Title: "Before startup"})
+
+	a, recorder := newTestApp(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := a.testConnection(ctx); err != nil {
+		t.Fatalf("testConnection: %v", err)
+	}
+	if err := a.initSyncCursor(ctx); err != nil {
+		t.Fatalf("initSyncCursor: %v", err)
+	}
+	go a.runSync(ctx)
+	go a.watchConnection(ctx)
+	go a.client.ConnectStream(ctx, a.handleStreamMessage)
+
+	if err := server.WaitForStream(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// A push tickle triggers a sync of pushes sent after startup only
+	push := server.AddPush(pushbullet.Push{Type: "note", Title: "Hello", Body: "from the phone"})
+	shown := waitForShown(t, recorder, 1)
+	if shown[0].Title != "Hello" {
+		t.Errorf("notification = %+v, want the new push", shown[0])
+	}
+
+	server.SendEphemeral(&pushbullet.Mirror{PackageName: "com.example.chat", ApplicationName: "Chat", Title: "Alice", Body: "Hi"})
+	shown = waitForShown(t, recorder, 2)
+	if shown[1].Title != "Chat: Alice" {
+		t.Errorf("notification = %+v, want the mirror", shown[1])
+	}
+
+	events, err := a.history.Query(history.Query{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(events) != 2 || events[0].Title != "Hello" || events[1].App != "Chat" {
+		t.Errorf("history = %+v, want the push and the mirror", events)
+	}
+
+	st, err := state.Load("")
+	if err != nil {
+		t.Fatalf("state.Load: %v", err)
+	}
+	if st.LastModified != push.Modified {
+		t.Errorf("saved sync cursor = %v, want %v", st.LastModified, push.Modified)
+	}
+	if st.UserIden != server.User().Iden {
+		t.Errorf("saved user iden = %q, want %q", st.UserIden, server.User().Iden)
+	}
+}
+
+func TestCatchUpAfterRestart(t *testing.T) {
+	setTestDirs(t)
+	server := pbtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	ctx := context.Background()
+
+	a, _ := newTestApp(t, server)
+	if err := a.initSyncCursor(ctx); err != nil {
+		t.Fatalf("initSyncCursor: %v", err)
+	}
+
+	// Pushes sent while pushbulleter was not running
+	for _, title := range []string{"one", "two", "three"} {
+		server.AddPush(pushbullet.Push{Type: "note", Title: title})
+	}
+
+	a, recorder := newTestApp(t, server)
+	a.config.Sync.MaxCatchUp = 2
+	if err := a.initSyncCursor(ctx); err != nil {
+		t.Fatalf("initSyncCursor: %v", err)
+	}
+
+	shown := recorder.Shown()
+	if len(shown) != 3 {
+		t.Fatalf("shown %d notifications, want a summary and 2 pushes", len(shown))
+	}
+	if shown[0].Title != "Older missed pushes (1)" || shown[1].Title != "two" || shown[2].Title != "three" {
+		t.Errorf("shown %q, %q, %q", shown[0].Title, shown[1].Title, shown[2].Title)
+	}
+}
+
+func TestSyncWithoutCursor(t *testing.T) {
+	setTestDirs(t)
+	server := pbtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	ctx := context.Background()
+	server.AddPush(pushbullet.Push{Type: "note", Title: "old"})
+
+	// initSyncCursor failed, so the first sync starts without a mark
+	a, recorder := newTestApp(t, server)
+	if err := a.syncPushes(ctx); err != nil {
+		t.Fatalf("syncPushes: %v", err)
+	}
+	if shown := recorder.Shown(); len(shown) != 0 {
+		t.Fatalf("shown %d notifications for old pushes, want 0", len(shown))
+	}
+
+	server.AddPush(pushbullet.Push{Type: "note", Title: "new"})
+	if err := a.syncPushes(ctx); err != nil {
+		t.Fatalf("syncPushes: %v", err)
+	}
+	if shown := recorder.Shown(); len(shown) != 1 || shown[0].Title != "new" {
+		t.Errorf("shown %+v, want only the new push", shown)
+	}
+}
+
+func TestLegacyFiltersBeforeRules(t *testing.T) {
+	cfg := &config.Config{
+		Notifications: config.NotificationConfig{
+			Enabled:     true,
+			ShowMirrors: true,
+			Filters:     []string{"slack"},
+			Rules: []config.RuleConfig{
+				{Match: config.RuleMatch{Type: "mirror"}, Action: "allow", Urgency: "critical"},
+			},
+		},
+	}
+
+	recorder := notifications.NewRecorder()
+	notifManager, err := newNotificationManager(cfg, recorder)
+	if err != nil {
+		t.Fatalf("newNotificationManager: %v", err)
+	}
+
+	notifManager.HandleEphemeral(&pushbullet.Mirror{PackageName: "com.slack", ApplicationName: "Slack", Title: "hidden"})
+	notifManager.HandleEphemeral(&pushbullet.Mirror{PackageName: "com.example", ApplicationName: "Example", Title: "shown"})
+
+	shown := recorder.Shown()
+	if len(shown) != 1 || shown[0].Title != "Example: shown" {
+		t.Errorf("shown %+v, want only the unfiltered mirror", shown)
+	}
+}
+
+func TestPushToThisDesktop(t *testing.T) {
+	setTestDirs(t)
+	server := pbtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	a, recorder := newTestApp(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := a.registerDevice(ctx); err != nil {
+		t.Fatalf("registerDevice: %v", err)
+	}
+	if err := a.initSyncCursor(ctx); err != nil {
+		t.Fatalf("initSyncCursor: %v", err)
+	}
+	go a.runSync(ctx)
+	go a.client.ConnectStream(ctx, a.handleStreamMessage)
+
+	if err := server.WaitForStream(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pushes the user sends from their own devices have direction "self"
+	req := pushbullet.NewNote("For the laptop", "")
+	req.DeviceIden = a.state.DeviceIden
+	push, err := a.client.CreatePush(ctx, req)
+	if err != nil {
+		t.Fatalf("CreatePush: %v", err)
+	}
+	if push.Direction != "self" {
+		t.Fatalf("direction = %q, want self", push.Direction)
+	}
+
+	shown := waitForShown(t, recorder, 1)
+	if shown[0].Title != "For the laptop" {
+		t.Errorf("notification = %+v, want the push to this desktop", shown[0])
+	}
+}
+
+// fakeWatcher is a sysevents.Watcher driven by the test.
+type fakeWatcher struct {
+	events chan sysevents.Event
+	closed chan struct{}
+}
+
+func newFakeWatcher() *fakeWatcher {
+	return &fakeWatcher{
+		events: make(chan sysevents.Event),
+		closed: make(chan struct{}),
+	}
+}
+
+func (w *fakeWatcher) Events() <-chan sysevents.Event {
+	return w.events
+}
+
+func (w *fakeWatcher) Close() error {
+	close(w.closed)
+	return nil
+}
+
+func TestReconnectOnSystemEvents(t *testing.T) {
+	setTestDirs(t)
+	server := pbtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	a, _ := newTestApp(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := newFakeWatcher()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.watchSystem(ctx, watcher)
+	}()
+	go a.client.ConnectStream(ctx, a.handleStreamMessage)
+
+	if err := server.WaitForStream(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, event := range []sysevents.Event{sysevents.Resume, sysevents.NetworkUp} {
+		watcher.events <- event
+		if err := server.WaitForStream(5 * time.Second); err != nil {
+			t.Fatalf("no reconnect after %s: %v", event, err)
+		}
+	}
+
+	watcher.events <- sysevents.Suspend
+	if err := server.WaitForStream(200 * time.Millisecond); err == nil {
+		t.Error("reconnected after suspend")
+	}
+
+	cancel()
+	select {
+	case <-watcher.closed:
+	case <-time.After(5 * time.Second):
+		t.Error("watcher not closed after cancel")
+	}
+	<-done
+}
+
+func TestRegisterDevice(t *testing.T) {
+	setTestDirs(t)
+	server := pbtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	ctx := context.Background()
+
+	a, _ := newTestApp(t, server)
+	if err := a.registerDevice(ctx); err != nil {
+		t.Fatalf("registerDevice: %v", err)
+	}
+	devices := server.Devices()
+	if len(devices) != 1 || devices[0].Iden != a.state.DeviceIden {
+		t.Fatalf("devices = %+v, want this desktop", devices)
+	}
+
+	st, err := state.Load("")
+	if err != nil {
+		t.Fatalf("state.Load: %v", err)
+	}
+	if st.DeviceIden != devices[0].Iden {
+		t.Errorf("saved device iden = %q, want %q", st.DeviceIden, devices[0].Iden)
+	}
+
+	// The registered device is reused, and registered again once deleted
+	if err := a.registerDevice(ctx); err != nil {
+		t.Fatalf("registerDevice: %v", err)
+	}
+	if len(server.Devices()) != 1 {
+		t.Errorf("registered %d devices, want 1", len(server.Devices()))
+	}
+
+	if err := a.client.DeleteDevice(ctx, a.state.DeviceIden); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	if err := a.registerDevice(ctx); err != nil {
+		t.Fatalf("registerDevice: %v", err)
+	}
+	if devices := server.Devices(); len(devices) != 1 || devices[0].Iden != a.state.DeviceIden {
+		t.Errorf("devices = %+v, want a new registration", devices)
+	}
+}
+
+func TestRepauseWhileResumeDue(t *testing.T) {
+	setTestDirs(t)
+	server := pbtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	a, _ := newTestApp(t, server)
+
+	a.pause(time.Now().Add(10 * time.Millisecond))
+	deadline := time.Now().Add(5 * time.Second)
+	for paused, _ := a.notifManager.Paused(); paused; paused, _ = a.notifManager.Paused() {
+		if time.Now().After(deadline) {
+			t.Fatal("pause did not run out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The first pause's timer fires just after the user picks a new pause
+	a.pause(time.Now().Add(time.Minute))
+	a.stateMu.Lock()
+	gen := a.pauseGen
+	a.stateMu.Unlock()
+
+	until := time.Now().Add(time.Hour)
+	a.pause(until)
+	a.expirePause(gen)
+
+	if paused, pausedUntil := a.notifManager.Paused(); !paused || !pausedUntil.Equal(until) {
+		t.Errorf("Paused() = %v, %v, want paused until %v", paused, pausedUntil, until)
+	}
+
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	if !a.state.Paused || a.resumeTimer == nil {
+		t.Error("new pause was cancelled by the earlier timer")
+	}
+	a.resumeTimer.Stop()
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+
+	"pushbulleter/internal/pushbullet"
+)
+
+// registerDevice registers this desktop as a device named after the host,
+// unless the device from an earlier run still exists, and saves its iden in
+// the state file.
+func (a *App) registerDevice(ctx context.Context) error {
+	a.stateMu.Lock()
+	iden := a.state.DeviceIden
+	a.stateMu.Unlock()
+
+	if iden != "" {
+		devices, err := a.client.ListDevices(ctx)
+		if err != nil {
+			return err
+		}
+		for _, device := range devices {
+			if device.Iden == iden {
+				return nil
+			}
+		}
+		log.Printf("Device %s was deleted, registering again", iden)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "pushbulleter"
+	}
+
+	device, err := a.client.CreateDevice(ctx, &pushbullet.DeviceParams{
+		Nickname:     hostname,
+		Manufacturer: "pushbulleter",
+		Model:        runtime.GOOS,
+		Icon:         "desktop",
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Registered this desktop as device %q (%s)", device.Nickname, device.Iden)
+
+	a.notifManager.SetDeviceIden(device.Iden)
+
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+
+	a.state.DeviceIden = device.Iden
+	if err := a.state.Save(""); err != nil {
+		return fmt.Errorf("failed to save device iden: %w", err)
+	}
+
+	return nil
+}
@@ -1,75 +1,128 @@
 package app
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"time"
 
+	"pushbulleter/internal/history"
 	"pushbulleter/internal/pushbullet"
 )
 
-type Event struct {
-	Timestamp time.Time
-	Type      string
-	Title     string
-	Message   string
-	Raw       string
-}
-
-func HandleEvent(msg *pushbullet.StreamMessage) {
-	event := Event{
+// HandleEvent logs a stream message and returns it as a history event, or
+// nil for messages that are not worth keeping such as tickles.
+func HandleEvent(msg *pushbullet.StreamMessage) *history.Event {
+	event := &history.Event{
 		Timestamp: time.Now(),
 		Type:      msg.Type,
 	}
 
+	var title, message string
+	keep := false
+
 	switch msg.Type {
 	case "push":
-		if len(msg.Push) > 0 {
-			var push pushbullet.Push
-
-			if err := json.Unmarshal(msg.Push, &push); err == nil {
-				if push.Type == "dismissal" {
-					return
-				}
+		if msg.Ephemeral == nil {
+			return nil
+		}
 
-				event.Title = fmt.Sprintf("Push: %s", push.Type)
+		event.Type = msg.Ephemeral.EphemeralType()
+		title = fmt.Sprintf("Push: %s", event.Type)
 
-				// Special handling for SMS events
-				if push.Type == "sms_changed" && len(push.Notifications) > 0 {
-					notification := push.Notifications[0] // Show first notification
-					if notification.Title != "" {
-						event.Message = fmt.Sprintf("SMS from %s: %s", notification.Title, notification.Body)
-					} else {
-						event.Message = fmt.Sprintf("SMS: %s", notification.Body)
-					}
-				} else if push.Title != "" {
-					event.Message = push.Title
-				} else if push.Body != "" {
-					event.Message = push.Body
+		switch eph := msg.Ephemeral.(type) {
+		case *pushbullet.Dismissal:
+			message = fmt.Sprintf("%s notification dismissed", eph.PackageName)
+		case *pushbullet.SMSChanged:
+			// Special handling for SMS events
+			if len(eph.Notifications) > 0 {
+				notification := eph.Notifications[0] // Show first notification
+				if notification.Title != "" {
+					message = fmt.Sprintf("SMS from %s: %s", notification.Title, notification.Body)
 				} else {
-					event.Message = "No content"
+					message = fmt.Sprintf("SMS: %s", notification.Body)
 				}
+				event.App = "SMS"
+				event.Title = notification.Title
+				event.Message = notification.Body
+				keep = true
+			} else {
+				message = "No content"
+			}
+		case *pushbullet.Mirror:
+			if eph.Title != "" {
+				message = eph.Title
+			} else if eph.Body != "" {
+				message = eph.Body
+			} else {
+				message = "No content"
 			}
+			event.App = eph.ApplicationName
+			event.Title = eph.Title
+			event.Message = eph.Body
+			keep = true
+		case *pushbullet.Clip:
+			message = eph.Body
+			event.Title = "Clipboard"
+			event.Message = eph.Body
+			keep = true
+		case *pushbullet.MessagingExtensionReply:
+			message = eph.Message
+		default:
+			message = "No content"
 		}
 	case "nop":
-		//event.Title = "Keep-alive"
-		//event.Message = "Connection heartbeat"
-		return
+		return nil
 	case "tickle":
-		event.Title = "Data update"
-		event.Message = "Server data changed"
+		title = "Data update"
+		message = "Server data changed"
+		if msg.Subtype != "" {
+			message = fmt.Sprintf("Server %s data changed", msg.Subtype)
+		}
 	default:
-		event.Title = fmt.Sprintf("Unknown: %s", msg.Type)
-		event.Message = "Unknown message type"
+		title = fmt.Sprintf("Unknown: %s", msg.Type)
+		message = "Unknown message type"
 	}
 
-	// Store raw JSON for debugging
-	if rawData, err := json.MarshalIndent(msg, "", "  "); err == nil {
-		event.Raw = string(rawData)
+	// Log the event
+	log.Printf("%s: %s", title, message)
+
+	if !keep {
+		return nil
 	}
+	return event
+}
 
-	//log.Printf("debug: %s", event.Raw)
-	// Log the event
-	log.Printf("%s: %s", event.Title, event.Message)
+// pushEvent returns a push fetched from the server as a history event.
+func pushEvent(push *pushbullet.Push) history.Event {
+	event := history.Event{
+		Timestamp: time.Unix(0, int64(push.Created*float64(time.Second))),
+		Type:      push.Type,
+		App:       push.SenderName,
+		Title:     push.Title,
+		Message:   push.Body,
+		URL:       push.URL,
+	}
+
+	if push.Type == "file" {
+		if event.Title == "" {
+			event.Title = push.FileName
+		}
+		event.URL = push.FileURL
+	}
+
+	return event
+}
+
+// recordEvent adds an event to the tray's Recent menu and to the history, if
+// it is enabled.
+func (a *App) recordEvent(event history.Event) {
+	a.trayManager.AddRecent(event)
+
+	if a.history == nil {
+		return
+	}
+
+	if err := a.history.Append(event); err != nil {
+		log.Printf("Failed to record event: %v", err)
+	}
 }
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// serveMetrics serves stream connection health in the Prometheus text
+// format until ctx is done.
+func (a *App) serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", a.handleMetrics)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("Serving metrics on http://%s/metrics", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Failed to serve metrics: %v", err)
+	}
+}
+
+func (a *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m := a.client.StreamMetrics()
+
+	connected := 0
+	if m.Connected {
+		connected = 1
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	writeMetric(w, "pushbulleter_stream_connected", "gauge", "Whether the Pushbullet stream is connected.", float64(connected))
+	writeMetric(w, "pushbulleter_stream_connected_since_seconds", "gauge", "Unix time the current stream connection was established.", unixSeconds(m.ConnectedSince))
+	writeMetric(w, "pushbulleter_stream_last_heartbeat_seconds", "gauge", "Unix time of the last nop heartbeat from the stream.", unixSeconds(m.LastHeartbeat))
+	writeMetric(w, "pushbulleter_stream_last_message_seconds", "gauge", "Unix time of the last message from the stream.", unixSeconds(m.LastMessage))
+	writeMetric(w, "pushbulleter_stream_connects_total", "counter", "Stream connections established.", float64(m.Connects))
+	writeMetric(w, "pushbulleter_stream_disconnects_total", "counter", "Stream connections lost.", float64(m.Disconnects))
+	writeMetric(w, "pushbulleter_stream_heartbeat_timeouts_total", "counter", "Stream connections dropped because heartbeats stopped.", float64(m.HeartbeatTimeouts))
+	writeMetric(w, "pushbulleter_stream_messages_total", "counter", "Messages received from the stream.", float64(m.Messages))
+}
+
+func writeMetric(w http.ResponseWriter, name, metricType, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, metricType, name, value)
+}
+
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / float64(time.Second)
+}
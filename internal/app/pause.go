@@ -0,0 +1,98 @@
+package app
+
+import (
+	"log"
+	"time"
+)
+
+// restorePause pauses notifications again if they were paused when
+// pushbulleter last exited and the pause has not run out since.
+func (a *App) restorePause() {
+	a.stateMu.Lock()
+	paused, until := a.state.Paused, a.state.PausedUntil
+	a.stateMu.Unlock()
+
+	if !paused {
+		return
+	}
+	if !until.IsZero() && !time.Now().Before(until) {
+		a.resume()
+		return
+	}
+
+	a.pause(until)
+}
+
+// pause stops showing notifications until the given time, or until resumed
+// if it is zero.
+func (a *App) pause(until time.Time) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+
+	a.stopResumeTimer()
+	if !until.IsZero() {
+		gen := a.pauseGen
+		a.resumeTimer = time.AfterFunc(time.Until(until), func() { a.expirePause(gen) })
+	}
+
+	a.notifManager.Pause(until)
+	a.trayManager.SetPaused(true, until)
+
+	if until.IsZero() {
+		log.Println("Notifications paused")
+	} else {
+		log.Printf("Notifications paused until %s", until.Format("2006-01-02 15:04"))
+	}
+
+	a.state.Paused = true
+	a.state.PausedUntil = until
+	if err := a.state.Save(""); err != nil {
+		log.Printf("Failed to save pause state: %v", err)
+	}
+}
+
+// resume shows notifications again.
+func (a *App) resume() {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+
+	a.resumeLocked()
+}
+
+// expirePause resumes notifications when the pause started as generation gen
+// runs out, unless notifications were paused again or resumed since.
+func (a *App) expirePause(gen uint64) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+
+	if gen != a.pauseGen || !a.state.Paused {
+		return
+	}
+	a.resumeLocked()
+}
+
+// resumeLocked shows notifications again; a.stateMu must be held.
+func (a *App) resumeLocked() {
+	a.stopResumeTimer()
+
+	a.notifManager.Resume()
+	a.trayManager.SetPaused(false, time.Time{})
+
+	log.Println("Notifications resumed")
+
+	a.state.Paused = false
+	a.state.PausedUntil = time.Time{}
+	if err := a.state.Save(""); err != nil {
+		log.Printf("Failed to save pause state: %v", err)
+	}
+}
+
+// stopResumeTimer cancels the pending resume and invalidates any timer that
+// has already fired; a.stateMu must be held.
+func (a *App) stopResumeTimer() {
+	if a.resumeTimer != nil {
+		a.resumeTimer.Stop()
+		a.resumeTimer = nil
+	}
+	a.pauseGen++
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"pushbulleter/internal/pushbullet"
+)
+
+// initSyncCursor restores the push high-water mark from the state file and
+// shows pushes that arrived while pushbulleter was not running. Without a
+// saved mark it starts from the newest push on the account so that the first
+// sync only picks up pushes sent after startup.
+func (a *App) initSyncCursor(ctx context.Context) error {
+	a.syncMu.Lock()
+	a.lastModified = a.state.LastModified
+	a.syncMu.Unlock()
+
+	if a.lastModified > 0 {
+		return a.catchUp(ctx)
+	}
+
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+
+	return a.resetSyncCursor(ctx)
+}
+
+// catchUp shows pushes missed while offline, either individually or as a
+// single summary, limited to the configured maximum.
+func (a *App) catchUp(ctx context.Context) error {
+	pushes, err := a.fetchNewPushes(ctx)
+	if err != nil {
+		return err
+	}
+
+	syncCfg := a.config.Sync
+	if !syncCfg.CatchUp || len(pushes) == 0 {
+		return nil
+	}
+
+	log.Printf("Catching up on %d pushes missed while offline", len(pushes))
+
+	if syncCfg.Summarize {
+		a.notifManager.ShowSummary("Missed pushes", pushes)
+		return nil
+	}
+
+	// Show the most recent pushes individually and summarize the rest
+	if syncCfg.MaxCatchUp > 0 && len(pushes) > syncCfg.MaxCatchUp {
+		older := pushes[:len(pushes)-syncCfg.MaxCatchUp]
+		pushes = pushes[len(pushes)-syncCfg.MaxCatchUp:]
+		a.notifManager.ShowSummary("Older missed pushes", older)
+	}
+
+	for _, push := range pushes {
+		a.notifManager.HandlePush(push)
+	}
+
+	return nil
+}
+
+// requestSync schedules a push sync without blocking the stream reader.
+// Requests that arrive while a sync is already pending are coalesced.
+func (a *App) requestSync() {
+	select {
+	case a.syncCh <- struct{}{}:
+	default:
+	}
+}
+
+func (a *App) runSync(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.syncCh:
+			if err := a.syncPushes(ctx); err != nil {
+				log.Printf("Failed to sync pushes: %v", err)
+			}
+		}
+	}
+}
+
+// syncPushes fetches pushes modified since the last sync and passes new ones
+// to the notification manager.
+func (a *App) syncPushes(ctx context.Context) error {
+	pushes, err := a.fetchNewPushes(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, push := range pushes {
+		a.notifManager.HandlePush(push)
+	}
+
+	return nil
+}
+
+// fetchNewPushes returns pushes created since the last sync, oldest first,
+// and advances the high-water mark past everything that was fetched.
+func (a *App) fetchNewPushes(ctx context.Context) ([]*pushbullet.Push, error) {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+
+	// Without a mark every push on the account would look new
+	if a.lastModified == 0 {
+		return nil, a.resetSyncCursor(ctx)
+	}
+
+	since := a.lastModified
+	pushes, err := a.client.PushesSince(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pushes: %w", err)
+	}
+
+	// The API returns newest first; notify in the order pushes were sent
+	sort.Slice(pushes, func(i, j int) bool {
+		return pushes[i].Created < pushes[j].Created
+	})
+
+	var newPushes []*pushbullet.Push
+	for i := range pushes {
+		push := &pushes[i]
+		if push.Modified > a.lastModified {
+			a.lastModified = push.Modified
+		}
+
+		// Updates to older pushes (dismissals, deletions) also bump modified
+		if !push.Active || push.Dismissed || push.Created <= since {
+			continue
+		}
+
+		newPushes = append(newPushes, push)
+		a.recordEvent(pushEvent(push))
+	}
+
+	if a.lastModified != since {
+		a.saveSyncCursor(a.lastModified)
+	}
+
+	return newPushes, nil
+}
+
+// resetSyncCursor moves the high-water mark to the newest push on the account,
+// or to now if there are none; a.syncMu must be held.
+func (a *App) resetSyncCursor(ctx context.Context) error {
+	list, err := a.client.ListPushes(ctx, &pushbullet.ListPushesOptions{Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to fetch pushes: %w", err)
+	}
+
+	modified := float64(time.Now().UnixNano()) / float64(time.Second)
+	if len(list.Pushes) > 0 {
+		modified = list.Pushes[0].Modified
+	}
+
+	a.lastModified = modified
+	a.saveSyncCursor(modified)
+	return nil
+}
+
+func (a *App) saveSyncCursor(modified float64) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+
+	a.state.LastModified = modified
+	if err := a.state.Save(""); err != nil {
+		log.Printf("Failed to save sync state: %v", err)
+	}
+}
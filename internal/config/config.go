@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,17 +14,119 @@ type Config struct {
 	E2EEnabled bool   `yaml:"e2e_enabled"`
 	E2EKey     string `yaml:"e2e_key,omitempty"`
 
+	// APIURL and StreamURL replace the Pushbullet endpoints, e.g. with a
+	// proxy; the PUSHBULLETER_API_URL and PUSHBULLETER_STREAM_URL
+	// environment variables take precedence
+	APIURL    string `yaml:"api_url,omitempty"`
+	StreamURL string `yaml:"stream_url,omitempty"`
+
 	Notifications NotificationConfig `yaml:"notifications"`
+	Sync          SyncConfig         `yaml:"sync"`
+	History       HistoryConfig      `yaml:"history"`
+	Metrics       MetricsConfig      `yaml:"metrics,omitempty"`
 	GUI           GUIConfig          `yaml:"gui"`
 	Autostart     bool               `yaml:"autostart"`
 }
 
 type NotificationConfig struct {
-	Enabled     bool     `yaml:"enabled"`
-	ShowMirrors bool     `yaml:"show_mirrors"`
-	ShowSMS     bool     `yaml:"show_sms"`
-	ShowCalls   bool     `yaml:"show_calls"`
-	Filters     []string `yaml:"filters,omitempty"`
+	Enabled     bool `yaml:"enabled"`
+	ShowMirrors bool `yaml:"show_mirrors"`
+	ShowSMS     bool `yaml:"show_sms"`
+	ShowCalls   bool `yaml:"show_calls"`
+	// Filters hides mirrors whose package or app name contains any of the
+	// given substrings. Deprecated: use Rules.
+	Filters []string     `yaml:"filters,omitempty"`
+	Rules   []RuleConfig `yaml:"rules,omitempty"`
+
+	QuietHours QuietHoursConfig `yaml:"quiet_hours"`
+
+	// Backend selects how notifications are displayed: auto, dbus,
+	// notify-send, log or exec
+	Backend     string   `yaml:"backend"`
+	ExecCommand []string `yaml:"exec_command,omitempty"`
+}
+
+// RuleConfig is a notification rule. Rules are evaluated in order and the
+// first rule whose match conditions all hold decides how a push is shown.
+type RuleConfig struct {
+	Name  string    `yaml:"name,omitempty"`
+	Match RuleMatch `yaml:"match"`
+
+	// Action is "drop", or "allow" to show notifications that the show_*
+	// settings hide. Without an action the show_* settings still apply.
+	Action string `yaml:"action,omitempty"`
+
+	// Overrides applied to allowed notifications
+	Urgency string        `yaml:"urgency,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	Icon    string        `yaml:"icon,omitempty"`
+	Title   string        `yaml:"title,omitempty"`
+
+	// Hook is a command run whenever the rule matches
+	Hook []string `yaml:"hook,omitempty"`
+}
+
+// RuleMatch lists the conditions of a rule. Empty conditions always match.
+// Type, Package, AppName, SourceDevice and SenderEmail are case-insensitive
+// glob patterns; Title and Body are regular expressions.
+type RuleMatch struct {
+	Type         string      `yaml:"type,omitempty"`
+	Package      string      `yaml:"package,omitempty"`
+	AppName      string      `yaml:"app_name,omitempty"`
+	Title        string      `yaml:"title,omitempty"`
+	Body         string      `yaml:"body,omitempty"`
+	SourceDevice string      `yaml:"source_device,omitempty"`
+	SenderEmail  string      `yaml:"sender_email,omitempty"`
+	Time         *TimeWindow `yaml:"time,omitempty"`
+}
+
+// TimeWindow is a daily time range such as 22:00-07:00, optionally limited to
+// some weekdays (mon, tue, ...). Ranges that end before they start wrap past
+// midnight.
+type TimeWindow struct {
+	Days  []string `yaml:"days,omitempty"`
+	Start string   `yaml:"start"`
+	End   string   `yaml:"end"`
+}
+
+// QuietHoursConfig holds notifications back during the scheduled windows and
+// shows them as a single summary when quiet hours end.
+type QuietHoursConfig struct {
+	Enabled  bool            `yaml:"enabled"`
+	Timezone string          `yaml:"timezone,omitempty"`
+	Schedule []TimeWindow    `yaml:"schedule,omitempty"`
+	Allow    QuietHoursAllow `yaml:"allow,omitempty"`
+}
+
+// QuietHoursAllow lists notifications that are shown even during quiet hours.
+type QuietHoursAllow struct {
+	// Calls are phone numbers or contact names whose calls ring through;
+	// "*" allows all calls
+	Calls    []string `yaml:"calls,omitempty"`
+	Packages []string `yaml:"packages,omitempty"`
+}
+
+// SyncConfig controls how pushes missed while pushbulleter was not running
+// are shown on the next start.
+type SyncConfig struct {
+	CatchUp    bool `yaml:"catch_up"`
+	MaxCatchUp int  `yaml:"max_catch_up"`
+	Summarize  bool `yaml:"summarize"`
+}
+
+// HistoryConfig controls the event history kept under XDG_DATA_HOME. Zero
+// limits keep events forever.
+type HistoryConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	MaxEntries int           `yaml:"max_entries"`
+	MaxAge     time.Duration `yaml:"max_age"`
+}
+
+// MetricsConfig exposes connection health in the Prometheus text format.
+type MetricsConfig struct {
+	// Listen is the address to serve /metrics on, e.g. 127.0.0.1:9464;
+	// empty disables metrics
+	Listen string `yaml:"listen,omitempty"`
 }
 
 type GUIConfig struct {
@@ -49,6 +152,17 @@ func Load(configPath string) (*Config, error) {
 			ShowMirrors: true,
 			ShowSMS:     true,
 			ShowCalls:   true,
+			Backend:     "auto",
+		},
+		Sync: SyncConfig{
+			CatchUp:    true,
+			MaxCatchUp: 10,
+			Summarize:  false,
+		},
+		History: HistoryConfig{
+			Enabled:    true,
+			MaxEntries: 5000,
+			MaxAge:     30 * 24 * time.Hour,
 		},
 		GUI: GUIConfig{
 			ShowTrayIcon:   true,
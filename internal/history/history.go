@@ -0,0 +1,282 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errReadOnly = errors.New("history is opened read-only")
+
+// Event is a push, mirrored notification or SMS as recorded in the history.
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+	App       string    `json:"app,omitempty"`
+	Title     string    `json:"title,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	URL       string    `json:"url,omitempty"`
+}
+
+// Query selects events from the history. Zero fields match everything.
+type Query struct {
+	Since time.Time
+	Until time.Time
+	Types []string
+	// App and Text are case-insensitive substrings of the app name and of
+	// the title or message
+	App  string
+	Text string
+	// Limit keeps only the newest events
+	Limit int
+}
+
+// Store is an append-only JSON lines file of events. Old events are removed
+// once the file holds more than maxEntries events or they are older than
+// maxAge.
+type Store struct {
+	path       string
+	maxEntries int
+	maxAge     time.Duration
+
+	readOnly bool
+
+	mu    sync.Mutex
+	count int
+}
+
+// Open opens the history file, creating it on first use, and applies the
+// retention limits. A zero limit disables it.
+func Open(historyPath string, maxEntries int, maxAge time.Duration) (*Store, error) {
+	if historyPath == "" {
+		historyPath = getDefaultHistoryPath()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	s := &Store{
+		path:       historyPath,
+		maxEntries: maxEntries,
+		maxAge:     maxAge,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// OpenReadOnly opens the history file for queries only, so that it can be
+// read while pushbulleter is running. Events older than maxAge are skipped
+// but the file is never compacted or written.
+func OpenReadOnly(historyPath string, maxAge time.Duration) *Store {
+	if historyPath == "" {
+		historyPath = getDefaultHistoryPath()
+	}
+
+	return &Store{
+		path:     historyPath,
+		maxAge:   maxAge,
+		readOnly: true,
+	}
+}
+
+// Append records an event.
+func (s *Store) Append(event Event) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	// Rewrite the file only once in a while rather than on every event
+	s.count++
+	if s.maxEntries > 0 && s.count > s.maxEntries+s.maxEntries/10 {
+		return s.compact()
+	}
+
+	return nil
+}
+
+// Query returns the matching events, oldest first.
+func (s *Store) Query(q Query) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Event
+	for _, event := range events {
+		if q.matches(event) {
+			matched = append(matched, event)
+		}
+	}
+
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[len(matched)-q.Limit:]
+	}
+
+	return matched, nil
+}
+
+// Clear removes all events.
+func (s *Store) Clear() error {
+	if s.readOnly {
+		return errReadOnly
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(nil)
+}
+
+func (q *Query) matches(event Event) bool {
+	if !q.Since.IsZero() && event.Timestamp.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !event.Timestamp.Before(q.Until) {
+		return false
+	}
+
+	if len(q.Types) > 0 {
+		found := false
+		for _, t := range q.Types {
+			if strings.EqualFold(t, event.Type) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if q.App != "" && !containsFold(event.App, q.App) {
+		return false
+	}
+	if q.Text != "" && !containsFold(event.Title, q.Text) && !containsFold(event.Message, q.Text) {
+		return false
+	}
+
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// read returns the events within the age limit. Lines that cannot be parsed,
+// such as one cut short by a crash, are skipped.
+func (s *Store) read() ([]Event, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	var cutoff time.Time
+	if s.maxAge > 0 {
+		cutoff = time.Now().Add(-s.maxAge)
+	}
+
+	// The whole file is in memory already, so lines of any length are fine
+	var events []Event
+	for len(data) > 0 {
+		var line []byte
+		line, data, _ = bytes.Cut(data, []byte("\n"))
+
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			continue
+		}
+		if event.Timestamp.Before(cutoff) {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// compact drops events beyond the retention limits.
+func (s *Store) compact() error {
+	events, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if s.maxEntries > 0 && len(events) > s.maxEntries {
+		events = events[len(events)-s.maxEntries:]
+	}
+
+	return s.write(events)
+}
+
+func (s *Store) write(events []Event) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+	}
+
+	// Write to a temporary file first so a crash never loses the history
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	s.count = len(events)
+	return nil
+}
+
+func getDefaultHistoryPath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dataHome, "pushbulleter", "history.jsonl")
+}
@@ -0,0 +1,172 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	now := time.Now()
+	events := []Event{
+		{Timestamp: now.Add(-3 * time.Hour), Type: "note", Title: "Groceries", Message: "milk"},
+		{Timestamp: now.Add(-2 * time.Hour), Type: "mirror", App: "Slack", Title: "#general", Message: "hello"},
+		{Timestamp: now.Add(-1 * time.Hour), Type: "mirror", App: "Signal", Title: "Alice", Message: "Milk?"},
+		{Timestamp: now, Type: "link", Title: "Go", URL: "https://go.dev"},
+	}
+	for _, event := range events {
+		if err := store.Append(event); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"all", Query{}, []string{"Groceries", "#general", "Alice", "Go"}},
+		{"type", Query{Types: []string{"MIRROR"}}, []string{"#general", "Alice"}},
+		{"app", Query{App: "sla"}, []string{"#general"}},
+		{"text", Query{Text: "milk"}, []string{"Groceries", "Alice"}},
+		{"since", Query{Since: now.Add(-90 * time.Minute)}, []string{"Alice", "Go"}},
+		{"until", Query{Until: now.Add(-90 * time.Minute)}, []string{"Groceries", "#general"}},
+		{"limit keeps newest", Query{Limit: 2}, []string{"Alice", "Go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Query(tt.query)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+
+			var titles []string
+			for _, event := range got {
+				titles = append(titles, event.Title)
+			}
+			if len(titles) != len(tt.want) {
+				t.Fatalf("Query = %v, want %v", titles, tt.want)
+			}
+			for i := range titles {
+				if titles[i] != tt.want[i] {
+					t.Fatalf("Query = %v, want %v", titles, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	store, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	store.Append(Event{Timestamp: time.Now().Add(-48 * time.Hour), Type: "note", Title: "old"})
+	for i := 0; i < 5; i++ {
+		store.Append(Event{Timestamp: time.Now(), Type: "note", Title: "new"})
+	}
+
+	// A line cut short by a crash is skipped
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"timestamp":"2024`)
+	f.Close()
+
+	store, err = Open(path, 3, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	events, err := store.Query(Query{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(events) != 3 {
+		t.Errorf("%d events after reopening with limits, want 3", len(events))
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if events, _ := store.Query(Query{}); len(events) != 0 {
+		t.Errorf("%d events after Clear, want 0", len(events))
+	}
+}
+
+func TestLongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	long := strings.Repeat("x", 2*1024*1024)
+	store.Append(Event{Timestamp: time.Now(), Type: "clip", Message: long})
+	store.Append(Event{Timestamp: time.Now(), Type: "note", Title: "after"})
+
+	// Reopening compacts the file, which reads every line
+	store, err = Open(path, 10, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	events, err := store.Query(Query{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(events) != 2 || events[0].Message != long || events[1].Title != "after" {
+		t.Errorf("got %d events, want the long clip and the note", len(events))
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := Open(path, 0, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	now := time.Now()
+	for _, event := range []Event{
+		{Timestamp: now.Add(-48 * time.Hour), Type: "note", Title: "old"},
+		{Timestamp: now, Type: "note", Title: "new"},
+	} {
+		if err := store.Append(event); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	before, _ := os.ReadFile(path)
+
+	reader := OpenReadOnly(path, 24*time.Hour)
+	events, err := reader.Query(Query{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(events) != 1 || events[0].Title != "new" {
+		t.Errorf("Query = %+v, want only the new event", events)
+	}
+
+	if err := reader.Append(Event{Timestamp: now, Type: "note"}); err == nil {
+		t.Error("Append succeeded on a read-only store")
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("history file changed from %q to %q", before, after)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file exists: %v", err)
+	}
+
+	// A missing file is an empty history
+	events, err = OpenReadOnly(filepath.Join(t.TempDir(), "missing.jsonl"), 0).Query(Query{})
+	if err != nil || len(events) != 0 {
+		t.Errorf("Query on missing file = %v, %v", events, err)
+	}
+}
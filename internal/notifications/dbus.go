@@ -0,0 +1,196 @@
+package notifications
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dbusNotificationsName      = "org.freedesktop.Notifications"
+	dbusNotificationsPath      = "/org/freedesktop/Notifications"
+	dbusNotificationsInterface = "org.freedesktop.Notifications"
+)
+
+// Reasons passed to NotificationClosed, as defined by the Desktop
+// Notifications specification.
+const (
+	ClosedExpired   uint32 = 1
+	ClosedDismissed uint32 = 2
+	ClosedByCall    uint32 = 3
+	ClosedUndefined uint32 = 4
+)
+
+// imageData is the (iiibiiay) structure of the image-data hint
+type imageData struct {
+	Width         int32
+	Height        int32
+	RowStride     int32
+	HasAlpha      bool
+	BitsPerSample int32
+	Channels      int32
+	Data          []byte
+}
+
+// DBusNotifier talks to the notification server on the session bus directly,
+// which gives access to notification ids, actions and close signals.
+type DBusNotifier struct {
+	conn         *dbus.Conn
+	obj          dbus.BusObject
+	capabilities []string
+
+	mu       sync.Mutex
+	handlers EventHandlers
+}
+
+// NewDBusNotifier connects to the session bus and verifies that a
+// notification server is running.
+func NewDBusNotifier() (*DBusNotifier, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %w", err)
+	}
+
+	obj := conn.Object(dbusNotificationsName, dbusNotificationsPath)
+
+	var capabilities []string
+	if err := obj.Call(dbusNotificationsInterface+".GetCapabilities", 0).Store(&capabilities); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("no notification server on session bus: %w", err)
+	}
+
+	for _, member := range []string{"ActionInvoked", "NotificationClosed", "NotificationReplied"} {
+		if err := conn.AddMatchSignal(
+			dbus.WithMatchObjectPath(dbusNotificationsPath),
+			dbus.WithMatchInterface(dbusNotificationsInterface),
+			dbus.WithMatchMember(member),
+		); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to subscribe to %s: %w", member, err)
+		}
+	}
+
+	d := &DBusNotifier{
+		conn:         conn,
+		obj:          obj,
+		capabilities: capabilities,
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go d.listen(signals)
+
+	return d, nil
+}
+
+// SetHandlers registers callbacks for actions invoked by the user, inline
+// replies and notifications being closed.
+func (d *DBusNotifier) SetHandlers(handlers EventHandlers) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handlers = handlers
+}
+
+func (d *DBusNotifier) Show(n *Notification) (uint32, error) {
+	return d.notify(0, n)
+}
+
+func (d *DBusNotifier) Replace(id uint32, n *Notification) (uint32, error) {
+	return d.notify(id, n)
+}
+
+func (d *DBusNotifier) Close(id uint32) error {
+	return d.obj.Call(dbusNotificationsInterface+".CloseNotification", 0, id).Err
+}
+
+func (d *DBusNotifier) Capabilities() []string {
+	return d.capabilities
+}
+
+func (d *DBusNotifier) notify(replacesID uint32, n *Notification) (uint32, error) {
+	hints := map[string]dbus.Variant{
+		"urgency": dbus.MakeVariant(byte(n.Urgency)),
+	}
+
+	var actions []string
+	if hasCapability(d, "actions") {
+		for _, action := range n.Actions {
+			actions = append(actions, action.Key, action.Label)
+			if action.Key == inlineReplyKey {
+				hints["x-kde-reply-placeholder-text"] = dbus.MakeVariant("Reply…")
+			}
+		}
+	}
+	if n.Category != "" {
+		hints["category"] = dbus.MakeVariant(n.Category)
+	}
+	if n.SoundName != "" {
+		hints["sound-name"] = dbus.MakeVariant(n.SoundName)
+	}
+	if n.ImagePath != "" {
+		if img, err := loadRGBA(n.ImagePath); err == nil {
+			hints["image-data"] = dbus.MakeVariant(imageData{
+				Width:         int32(img.Rect.Dx()),
+				Height:        int32(img.Rect.Dy()),
+				RowStride:     int32(img.Stride),
+				HasAlpha:      true,
+				BitsPerSample: 8,
+				Channels:      4,
+				Data:          img.Pix,
+			})
+		} else {
+			hints["image-path"] = dbus.MakeVariant(n.ImagePath)
+		}
+	}
+
+	var id uint32
+	call := d.obj.Call(dbusNotificationsInterface+".Notify", 0,
+		"Pushbulleter",
+		replacesID,
+		n.Icon,
+		n.Title,
+		n.Body,
+		actions,
+		hints,
+		int32(n.Timeout.Milliseconds()),
+	)
+	if err := call.Store(&id); err != nil {
+		return 0, fmt.Errorf("failed to send notification over D-Bus: %w", err)
+	}
+
+	return id, nil
+}
+
+func (d *DBusNotifier) listen(signals <-chan *dbus.Signal) {
+	for signal := range signals {
+		if signal.Path != dbusNotificationsPath || len(signal.Body) < 2 {
+			continue
+		}
+
+		id, ok := signal.Body[0].(uint32)
+		if !ok {
+			continue
+		}
+
+		d.mu.Lock()
+		handlers := d.handlers
+		d.mu.Unlock()
+
+		switch signal.Name {
+		case dbusNotificationsInterface + ".ActionInvoked":
+			if key, ok := signal.Body[1].(string); ok && handlers.OnAction != nil {
+				handlers.OnAction(id, key)
+			}
+		case dbusNotificationsInterface + ".NotificationClosed":
+			if reason, ok := signal.Body[1].(uint32); ok && handlers.OnClosed != nil {
+				handlers.OnClosed(id, reason)
+			}
+		case dbusNotificationsInterface + ".NotificationReplied":
+			if text, ok := signal.Body[1].(string); ok && handlers.OnReplied != nil {
+				handlers.OnReplied(id, text)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"pushbulleter/internal/pushbullet"
+)
+
+// Responder sends the user's responses to mirrored notifications back to the
+// phone. *pushbullet.Client implements it.
+type Responder interface {
+	DismissMirror(ctx context.Context, mirror *pushbullet.Mirror) error
+	ReplyToMirror(ctx context.Context, mirror *pushbullet.Mirror, message string) error
+	SendSMS(ctx context.Context, deviceIden, conversationIden, message string) error
+}
+
+// SetResponder enables propagating desktop interactions to the phone.
+func (m *Manager) SetResponder(responder Responder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.responder = responder
+}
+
+// mirrorKey identifies an Android notification across mirror and dismissal
+// ephemerals.
+type mirrorKey struct {
+	sourceDeviceIden string
+	packageName      string
+	notificationID   string
+	notificationTag  string
+}
+
+func keyForMirror(mirror *pushbullet.Mirror) mirrorKey {
+	return mirrorKey{
+		sourceDeviceIden: mirror.SourceDeviceIden,
+		packageName:      mirror.PackageName,
+		notificationID:   string(mirror.NotificationID),
+		notificationTag:  mirror.NotificationTag,
+	}
+}
+
+func keyForDismissal(dismissal *pushbullet.Dismissal) mirrorKey {
+	return mirrorKey{
+		sourceDeviceIden: dismissal.SourceDeviceIden,
+		packageName:      dismissal.PackageName,
+		notificationID:   string(dismissal.NotificationID),
+		notificationTag:  dismissal.NotificationTag,
+	}
+}
+
+// matches compares keys, treating an empty source device as a wildcard since
+// dismissals do not always carry it.
+func (k mirrorKey) matches(other mirrorKey) bool {
+	if k.sourceDeviceIden != "" && other.sourceDeviceIden != "" && k.sourceDeviceIden != other.sourceDeviceIden {
+		return false
+	}
+	return k.packageName == other.packageName &&
+		k.notificationID == other.notificationID &&
+		k.notificationTag == other.notificationTag
+}
+
+// mirrorNotificationID returns the desktop notification currently showing
+// the given mirror, or zero.
+func (m *Manager) mirrorNotificationID(mirror *pushbullet.Mirror) uint32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.mirrorIDs[keyForMirror(mirror)]
+}
+
+func (m *Manager) trackMirror(id uint32, mirror *pushbullet.Mirror) {
+	if id == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.track(id)
+	m.mirrorIDs[keyForMirror(mirror)] = id
+	m.mirrors[id] = mirror
+}
+
+// track records that state is kept for a notification and drops the state of
+// the oldest ones beyond maxTracked, since backends without close signals
+// never report notifications gone; m.mu must be held.
+func (m *Manager) track(id uint32) {
+	for _, tracked := range m.tracked {
+		if tracked == id {
+			return
+		}
+	}
+
+	m.tracked = append(m.tracked, id)
+	for len(m.tracked) > maxTracked {
+		m.forget(m.tracked[0])
+	}
+}
+
+// forgetNotification drops all state kept for a desktop notification.
+func (m *Manager) forgetNotification(id uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.forget(id)
+}
+
+// forget is forgetNotification with m.mu held.
+func (m *Manager) forget(id uint32) {
+	for i, tracked := range m.tracked {
+		if tracked == id {
+			m.tracked = append(m.tracked[:i:i], m.tracked[i+1:]...)
+			break
+		}
+	}
+
+	delete(m.actions, id)
+	if mirror, ok := m.mirrors[id]; ok {
+		delete(m.mirrors, id)
+		key := keyForMirror(mirror)
+		if m.mirrorIDs[key] == id {
+			delete(m.mirrorIDs, key)
+		}
+	}
+}
+
+// handleDismissal closes the desktop notification of a mirror that was
+// dismissed on the phone.
+func (m *Manager) handleDismissal(dismissal *pushbullet.Dismissal) {
+	key := keyForDismissal(dismissal)
+
+	var ids []uint32
+	m.mu.Lock()
+	for mirrorKey, id := range m.mirrorIDs {
+		if mirrorKey.matches(key) {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		if err := m.notifier.Close(id); err != nil && !errors.Is(err, ErrUnsupported) {
+			log.Printf("Failed to close dismissed notification: %v", err)
+		}
+		m.forgetNotification(id)
+	}
+}
+
+// dismissOnPhone dismisses a mirror on the phone after the user closed its
+// desktop notification.
+func (m *Manager) dismissOnPhone(id uint32) {
+	m.mu.Lock()
+	mirror := m.mirrors[id]
+	responder := m.responder
+	m.mu.Unlock()
+
+	if mirror == nil || !mirror.Dismissable || responder == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := responder.DismissMirror(ctx, mirror); err != nil {
+			log.Printf("Failed to dismiss notification on phone: %v", err)
+		}
+	}()
+}
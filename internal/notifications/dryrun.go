@@ -0,0 +1,83 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"pushbulleter/internal/pushbullet"
+)
+
+// DryRunResult describes how a sample push would be handled.
+type DryRunResult struct {
+	// Rule is the name of the rule matching the sample, empty if none did
+	Rule string
+	Drop bool
+
+	// Notifications lists the notifications that would be shown
+	Notifications []*Notification
+}
+
+// DryRun evaluates the manager's rules and settings against a push or
+// ephemeral in JSON form without showing notifications or running hooks.
+// For SMS updates with several messages, Rule refers to the first message.
+func (m *Manager) DryRun(data []byte) (*DryRunResult, error) {
+	recorder := NewRecorder()
+
+	m.mu.Lock()
+	enabled, rules := m.enabled, m.rules
+	m.mu.Unlock()
+
+	dry := NewManager(recorder, enabled, m.showMirrors, m.showSMS, m.showCalls)
+	dry.rules = rules
+	dry.runHooks = false
+	dry.icons = nil
+
+	// Accept whole stream messages as well as their contents
+	var envelope struct {
+		Type string          `json:"type"`
+		Push json.RawMessage `json:"push"`
+	}
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Type == "push" && len(envelope.Push) > 0 {
+		data = envelope.Push
+	}
+
+	eph, err := pushbullet.DecodeEphemeral(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject *ruleSubject
+	switch e := eph.(type) {
+	case *pushbullet.Mirror:
+		s := mirrorSubject(e)
+		subject = &s
+		dry.HandleEphemeral(e)
+	case *pushbullet.SMSChanged:
+		if len(e.Notifications) > 0 {
+			s := smsSubject(e, e.Notifications[0])
+			subject = &s
+		}
+		dry.HandleEphemeral(e)
+	case *pushbullet.UnknownEphemeral:
+		// Anything that is not an ephemeral is treated as a regular push
+		var push pushbullet.Push
+		if err := json.Unmarshal(data, &push); err != nil {
+			return nil, fmt.Errorf("failed to parse push: %w", err)
+		}
+		s := pushSubject(&push)
+		subject = &s
+		dry.HandlePush(&push)
+	default:
+		return nil, fmt.Errorf("%s ephemerals do not create notifications", eph.EphemeralType())
+	}
+
+	result := &DryRunResult{Notifications: recorder.Shown()}
+	if subject != nil {
+		if rule := dry.matchRule(*subject); rule != nil {
+			result.Rule = rule.Name
+			result.Drop = rule.Drop
+		}
+	}
+
+	return result, nil
+}
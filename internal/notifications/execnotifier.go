@@ -0,0 +1,72 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ExecNotifier hands notifications to a user-supplied command. The command
+// receives the notification through PUSHBULLETER_* environment variables,
+// with PUSHBULLETER_EVENT set to "show", "replace" or "close".
+type ExecNotifier struct {
+	command []string
+	lastID  atomic.Uint32
+}
+
+func NewExecNotifier(command []string) (*ExecNotifier, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("exec notification backend requires exec_command")
+	}
+
+	return &ExecNotifier{command: command}, nil
+}
+
+func (e *ExecNotifier) Show(n *Notification) (uint32, error) {
+	id := e.lastID.Add(1)
+	return id, e.run("show", id, n)
+}
+
+func (e *ExecNotifier) Replace(id uint32, n *Notification) (uint32, error) {
+	return id, e.run("replace", id, n)
+}
+
+func (e *ExecNotifier) Close(id uint32) error {
+	return e.run("close", id, nil)
+}
+
+func (e *ExecNotifier) Capabilities() []string {
+	return []string{"body"}
+}
+
+func (e *ExecNotifier) run(event string, id uint32, n *Notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.command[0], e.command[1:]...)
+	cmd.Env = append(os.Environ(),
+		"PUSHBULLETER_EVENT="+event,
+		"PUSHBULLETER_ID="+strconv.FormatUint(uint64(id), 10),
+	)
+	if n != nil {
+		cmd.Env = append(cmd.Env,
+			"PUSHBULLETER_TITLE="+n.Title,
+			"PUSHBULLETER_BODY="+n.Body,
+			"PUSHBULLETER_ICON="+n.Icon,
+			"PUSHBULLETER_IMAGE="+n.ImagePath,
+			"PUSHBULLETER_CATEGORY="+n.Category,
+			"PUSHBULLETER_URGENCY="+n.Urgency.String(),
+			"PUSHBULLETER_TIMEOUT="+strconv.FormatInt(n.Timeout.Milliseconds(), 10),
+		)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notification command failed: %w: %s", err, output)
+	}
+
+	return nil
+}
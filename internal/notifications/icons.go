@@ -0,0 +1,101 @@
+package notifications
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// iconCache stores the app icons sent with mirrors on disk so that
+// notification backends can refer to them by path.
+type iconCache struct {
+	dir string
+}
+
+func newIconCache() *iconCache {
+	return &iconCache{dir: getDefaultIconDir()}
+}
+
+// path returns the cached file for a base64 encoded icon, writing it on first
+// use. Files are keyed by package name and content hash so that apps
+// changing their icon get a new file.
+func (c *iconCache) path(packageName, icon string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(icon)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode icon: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	name := fmt.Sprintf("%s-%s.jpg", sanitizeFileName(packageName), hex.EncodeToString(sum[:8]))
+	iconPath := filepath.Join(c.dir, name)
+
+	if _, err := os.Stat(iconPath); err == nil {
+		return iconPath, nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create icon cache directory: %w", err)
+	}
+
+	if err := os.WriteFile(iconPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write icon: %w", err)
+	}
+
+	return iconPath, nil
+}
+
+func sanitizeFileName(name string) string {
+	if name == "" {
+		return "unknown"
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// loadRGBA reads an image file and converts it to 8-bit RGBA, the layout
+// expected by the image-data notification hint.
+func loadRGBA(imagePath string) (*image.RGBA, error) {
+	f, err := os.Open(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba, nil
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba, nil
+}
+
+func getDefaultIconDir() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		cacheHome = filepath.Join(homeDir, ".cache")
+	}
+	return filepath.Join(cacheHome, "pushbulleter", "icons")
+}
@@ -0,0 +1,36 @@
+package notifications
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// LogNotifier writes notifications to the log instead of the desktop, for
+// headless machines.
+type LogNotifier struct {
+	lastID atomic.Uint32
+}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (l *LogNotifier) Show(n *Notification) (uint32, error) {
+	id := l.lastID.Add(1)
+	log.Printf("Notification %d [%s]: %s: %s", id, n.Urgency, n.Title, n.Body)
+	return id, nil
+}
+
+func (l *LogNotifier) Replace(id uint32, n *Notification) (uint32, error) {
+	log.Printf("Notification %d replaced [%s]: %s: %s", id, n.Urgency, n.Title, n.Body)
+	return id, nil
+}
+
+func (l *LogNotifier) Close(id uint32) error {
+	log.Printf("Notification %d closed", id)
+	return nil
+}
+
+func (l *LogNotifier) Capabilities() []string {
+	return []string{"body"}
+}
@@ -1,61 +1,88 @@
 package notifications
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"pushbulleter/internal/pushbullet"
 )
 
+// maxSummaryLines limits how many pushes are listed in a summary notification
+const maxSummaryLines = 5
+
+// maxTracked limits the number of notifications whose actions and mirrors
+// are remembered
+const maxTracked = 200
+
 type Manager struct {
-	enabled     bool
 	showMirrors bool
 	showSMS     bool
 	showCalls   bool
-	filters     []string
+
+	notifier Notifier
+	icons    *iconCache
+
+	mu          sync.Mutex
+	enabled     bool
+	deviceIden  string
+	paused      bool
+	pausedUntil time.Time
+	quietHours  *QuietHours
+	quietQueue  []*Notification
+	quietCount  int
+	rules       []*Rule
+	runHooks    bool
+	responder   Responder
+	actions     map[uint32][]Action
+	mirrors     map[uint32]*pushbullet.Mirror
+	mirrorIDs   map[mirrorKey]uint32
+	tracked     []uint32
 }
 
-func NewManager(enabled, showMirrors, showSMS, showCalls bool, filters []string) *Manager {
-	return &Manager{
+func NewManager(notifier Notifier, enabled, showMirrors, showSMS, showCalls bool) *Manager {
+	m := &Manager{
 		enabled:     enabled,
 		showMirrors: showMirrors,
 		showSMS:     showSMS,
 		showCalls:   showCalls,
-		filters:     filters,
+		notifier:    notifier,
+		icons:       newIconCache(),
+		runHooks:    true,
+		actions:     make(map[uint32][]Action),
+		mirrors:     make(map[uint32]*pushbullet.Mirror),
+		mirrorIDs:   make(map[mirrorKey]uint32),
+	}
+
+	if source, ok := notifier.(EventSource); ok {
+		source.SetHandlers(EventHandlers{
+			OnAction:  m.handleAction,
+			OnClosed:  m.handleClosed,
+			OnReplied: m.handleReplied,
+		})
 	}
+
+	return m
 }
 
 func (m *Manager) HandlePush(push *pushbullet.Push) {
-	if !m.enabled {
+	if !m.active() {
 		return
 	}
 
-	// Check if we should show this notification
+	// Rules cannot bring back pushes sent from this account or meant for
+	// another device
 	if !m.shouldNotify(push) {
 		return
 	}
 
-	// Special handling for SMS notifications
-	if push.Type == "sms_changed" && len(push.Notifications) > 0 {
-		// Show notification for each SMS
-		for _, notification := range push.Notifications {
-			title := "💬 SMS"
-			if notification.Title != "" {
-				title = "💬 " + notification.Title
-			}
-
-			message := notification.Body
-			if message == "" {
-				message = "New SMS message"
-			}
-
-			if err := m.showEnhancedNotification(title, message, "sms"); err != nil {
-				log.Printf("Failed to show SMS notification: %v", err)
-			}
-		}
+	subject := pushSubject(push)
+	rule := m.matchRule(subject)
+	if rule != nil && rule.Drop {
 		return
 	}
 
@@ -64,93 +91,283 @@ func (m *Manager) HandlePush(push *pushbullet.Push) {
 		return
 	}
 
+	n := newNotification(title, message, push.Type)
+	if push.Type == "link" && push.URL != "" {
+		n.Actions = append(n.Actions, Action{
+			Key:     "default",
+			Label:   "Open",
+			Handler: func() { OpenURL(push.URL) },
+		})
+	}
+	if rule != nil {
+		rule.apply(n, subject)
+	}
+	if m.holdForQuietHours(subject, n) {
+		return
+	}
+
 	// Show Linux desktop notification
-	if err := m.showEnhancedNotification(title, message, push.Type); err != nil {
+	if _, err := m.show(n); err != nil {
 		log.Printf("Failed to show notification: %v", err)
+		return
 	}
+	m.runHook(rule, subject)
 }
 
-func (m *Manager) shouldNotify(push *pushbullet.Push) bool {
-	switch push.Type {
-	case "mirror":
-		if !m.showMirrors {
-			return false
-		}
+// HandleEphemeral shows desktop notifications for ephemerals received on the
+// stream, such as mirrored Android notifications and SMS updates.
+func (m *Manager) HandleEphemeral(eph pushbullet.Ephemeral) {
+	// Stale popups are closed even while notifications are disabled
+	if dismissal, ok := eph.(*pushbullet.Dismissal); ok {
+		m.handleDismissal(dismissal)
+		return
+	}
 
-		// Check for SMS/call specific filtering
-		if push.PackageName == "com.android.phone" && !m.showCalls {
-			return false
-		}
-		if (push.PackageName == "com.android.mms" ||
-			push.PackageName == "com.google.android.apps.messaging" ||
-			strings.Contains(strings.ToLower(push.ApplicationName), "sms")) && !m.showSMS {
-			return false
-		}
+	if !m.active() {
+		return
+	}
 
-	case "sms_changed":
-		return m.showSMS
+	switch e := eph.(type) {
+	case *pushbullet.Mirror:
+		m.handleMirror(e)
+	case *pushbullet.SMSChanged:
+		m.handleSMSChanged(e)
+	}
+}
 
-	default:
-		// For other push types, apply general filtering
-		if push.Direction == "self" {
-			return false // Don't notify for pushes from ourselves
+func (m *Manager) handleMirror(mirror *pushbullet.Mirror) {
+	subject := mirrorSubject(mirror)
+	rule := m.matchRule(subject)
+	if rule != nil && rule.Drop {
+		return
+	}
+	if (rule == nil || !rule.Allow) && !m.shouldNotifyMirror(mirror) {
+		return
+	}
+
+	title, message := m.formatMirror(mirror)
+	n := newNotification(title, message, "mirror")
+
+	// Show the Android app's own icon when the phone sends one
+	if mirror.Icon != "" && m.icons != nil {
+		if iconPath, err := m.icons.path(mirror.PackageName, mirror.Icon); err == nil {
+			n.ImagePath = iconPath
+		} else {
+			log.Printf("Failed to cache icon for %s: %v", mirror.PackageName, err)
 		}
 	}
 
-	// Apply custom filters
-	for _, filter := range m.filters {
-		if strings.Contains(strings.ToLower(push.PackageName), strings.ToLower(filter)) ||
-			strings.Contains(strings.ToLower(push.ApplicationName), strings.ToLower(filter)) {
-			return false
+	// Messaging apps that accept replies identify the conversation
+	if mirror.ConversationIden != "" {
+		reply, ok := m.replyAction(title, func(ctx context.Context, responder Responder, text string) error {
+			return responder.ReplyToMirror(ctx, mirror, text)
+		})
+		if ok {
+			n.Actions = append(n.Actions, reply)
 		}
 	}
 
-	return true
+	if rule != nil {
+		rule.apply(n, subject)
+	}
+	if m.holdForQuietHours(subject, n) {
+		return
+	}
+
+	// Android updates notifications in place, so replace the desktop
+	// notification already showing this mirror
+	var id uint32
+	var err error
+	if existing := m.mirrorNotificationID(mirror); existing != 0 {
+		id, err = m.notifier.Replace(existing, n)
+		if err == nil && id != 0 {
+			m.registerActions(id, n.Actions)
+		}
+	} else {
+		id, err = m.show(n)
+	}
+	if err != nil {
+		log.Printf("Failed to show notification: %v", err)
+		return
+	}
+
+	m.trackMirror(id, mirror)
+	m.runHook(rule, subject)
 }
 
-func (m *Manager) formatNotification(push *pushbullet.Push) (string, string) {
-	switch push.Type {
-	case "mirror":
-		title := push.ApplicationName
-		if push.Title != "" {
-			title = fmt.Sprintf("%s: %s", push.ApplicationName, push.Title)
+func (m *Manager) handleSMSChanged(sms *pushbullet.SMSChanged) {
+	// Show notification for each SMS
+	for _, notification := range sms.Notifications {
+		subject := smsSubject(sms, notification)
+		rule := m.matchRule(subject)
+		if rule != nil && rule.Drop {
+			continue
+		}
+		if (rule == nil || !rule.Allow) && !m.showSMS {
+			continue
 		}
 
-		message := push.Body
+		title := "💬 SMS"
+		if notification.Title != "" {
+			title = "💬 " + notification.Title
+		}
+
+		message := notification.Body
+		if message == "" {
+			message = "New SMS message"
+		}
 
-		// Special handling for calls
-		if push.PackageName == "com.android.phone" {
-			if strings.Contains(strings.ToLower(push.Body), "incoming call") {
-				title = "📞 Incoming Call"
-			} else if strings.Contains(strings.ToLower(push.Body), "missed call") {
-				title = "📞 Missed Call"
+		n := newNotification(title, message, "sms")
+		if notification.ThreadID != "" {
+			deviceIden, threadID := sms.SourceDeviceIden, notification.ThreadID
+			reply, ok := m.replyAction(title, func(ctx context.Context, responder Responder, text string) error {
+				return responder.SendSMS(ctx, deviceIden, threadID, text)
+			})
+			if ok {
+				n.Actions = append(n.Actions, reply)
 			}
 		}
+		if rule != nil {
+			rule.apply(n, subject)
+		}
+		if m.holdForQuietHours(subject, n) {
+			continue
+		}
 
-		// Special handling for SMS
-		if push.PackageName == "com.android.mms" ||
-			push.PackageName == "com.google.android.apps.messaging" ||
-			strings.Contains(strings.ToLower(push.ApplicationName), "sms") {
-			title = "💬 " + title
+		if _, err := m.show(n); err != nil {
+			log.Printf("Failed to show SMS notification: %v", err)
+			continue
 		}
+		m.runHook(rule, subject)
+	}
+}
 
-		return title, message
+// ShowSummary shows a single notification listing several pushes, for
+// example pushes that arrived while pushbulleter was not running.
+func (m *Manager) ShowSummary(title string, pushes []*pushbullet.Push) {
+	if !m.active() {
+		return
+	}
 
-	case "sms_changed":
-		// For sms_changed events, we should show the actual SMS content
-		// The title and body should contain the SMS details
-		title := "💬 SMS"
-		message := "New SMS activity"
+	var lines []string
+	for _, push := range pushes {
+		if !m.shouldNotify(push) {
+			continue
+		}
+		if rule := m.matchRule(pushSubject(push)); rule != nil && rule.Drop {
+			continue
+		}
 
-		if push.Title != "" {
-			title = "💬 " + push.Title
+		pushTitle, pushMessage := m.formatNotification(push)
+		if pushTitle != "" || pushMessage != "" {
+			lines = append(lines, summaryLine(pushTitle, pushMessage))
 		}
-		if push.Body != "" {
-			message = push.Body
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	if err := m.showSummaryLines(title, lines, len(lines)); err != nil {
+		log.Printf("Failed to show summary notification: %v", err)
+	}
+}
+
+// showSummaryLines shows one notification listing the first few lines; total
+// is the number of items being summarized, which may exceed len(lines).
+func (m *Manager) showSummaryLines(title string, lines []string, total int) error {
+	shown := min(len(lines), maxSummaryLines)
+	lines = lines[:shown:shown]
+	if total > shown {
+		lines = append(lines, fmt.Sprintf("…and %d more", total-shown))
+	}
+
+	return m.showEnhancedNotification(fmt.Sprintf("%s (%d)", title, total), strings.Join(lines, "\n"), "summary")
+}
+
+// ShowAlert shows a problem with pushbulleter itself, such as a rejected API
+// key. Alerts are shown even when notifications are paused or disabled.
+func (m *Manager) ShowAlert(title, message string) {
+	if err := m.showEnhancedNotification(title, message, "alert"); err != nil {
+		log.Printf("Failed to show alert: %v", err)
+	}
+}
+
+func summaryLine(title, message string) string {
+	if title != "" && message != "" {
+		return fmt.Sprintf("%s: %s", title, message)
+	}
+	return title + message
+}
+
+// SetDeviceIden sets this desktop's device iden. Pushes targeted at it are
+// then shown even when sent from the user's own devices, and pushes targeted
+// at other devices are ignored.
+func (m *Manager) SetDeviceIden(iden string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deviceIden = iden
+}
+
+func (m *Manager) shouldNotify(push *pushbullet.Push) bool {
+	m.mu.Lock()
+	deviceIden := m.deviceIden
+	m.mu.Unlock()
+
+	// Pushes sent to this desktop are shown even when they come from the
+	// user's own devices, and pushes for other devices never are
+	if deviceIden != "" && push.TargetDeviceIden != "" {
+		return push.TargetDeviceIden == deviceIden
+	}
+
+	// Don't notify for pushes from ourselves
+	return push.Direction != "self"
+}
+
+func (m *Manager) shouldNotifyMirror(mirror *pushbullet.Mirror) bool {
+	if !m.showMirrors {
+		return false
+	}
+
+	// Check for SMS/call specific filtering
+	if isCallApp(mirror.PackageName) && !m.showCalls {
+		return false
+	}
+	if isSMSApp(mirror.PackageName, mirror.ApplicationName) && !m.showSMS {
+		return false
+	}
+
+	return true
+}
+
+func (m *Manager) formatMirror(mirror *pushbullet.Mirror) (string, string) {
+	title := mirror.ApplicationName
+	if mirror.Title != "" {
+		title = fmt.Sprintf("%s: %s", mirror.ApplicationName, mirror.Title)
+	}
+
+	message := mirror.Body
+
+	// Special handling for calls
+	if isCallApp(mirror.PackageName) {
+		if strings.Contains(strings.ToLower(mirror.Body), "incoming call") {
+			title = "📞 Incoming Call"
+		} else if strings.Contains(strings.ToLower(mirror.Body), "missed call") {
+			title = "📞 Missed Call"
 		}
+	}
 
-		return title, message
+	// Special handling for SMS
+	if isSMSApp(mirror.PackageName, mirror.ApplicationName) {
+		title = "💬 " + title
+	}
+
+	return title, message
+}
 
+func (m *Manager) formatNotification(push *pushbullet.Push) (string, string) {
+	switch push.Type {
 	case "note":
 		title := "📝 Note"
 		if push.Title != "" {
@@ -181,89 +398,77 @@ func (m *Manager) formatNotification(push *pushbullet.Push) (string, string) {
 	return "", ""
 }
 
+func isCallApp(packageName string) bool {
+	return packageName == "com.android.phone"
+}
+
+func isSMSApp(packageName, applicationName string) bool {
+	return packageName == "com.android.mms" ||
+		packageName == "com.google.android.apps.messaging" ||
+		strings.Contains(strings.ToLower(applicationName), "sms")
+}
+
 // showEnhancedNotification shows a notification optimized for Linux/XFCE
 func (m *Manager) showEnhancedNotification(title, message, notificationType string) error {
-	// Use notify-send directly - this is the standard for Linux desktop environments
-	return m.showNotifyDesktopNotification(title, message, notificationType)
+	_, err := m.show(newNotification(title, message, notificationType))
+	return err
 }
 
-// showNotifyDesktopNotification uses notify-send with XFCE-optimized options
-func (m *Manager) showNotifyDesktopNotification(title, message, notificationType string) error {
-	// notify-send is required for Linux desktop notifications
-	if _, err := exec.LookPath("notify-send"); err != nil {
-		return fmt.Errorf("notify-send not available - please install libnotify-bin: %w", err)
-	}
-
-	args := []string{
-		"--app-name=Pushbulleter",
-		"--expire-time=12000", // Show for 12 seconds (good for XFCE)
-		"--urgency=normal",    // Default urgency
-	}
-
-	// XFCE-optimized settings based on notification type
-	switch notificationType {
-	case "sms", "sms_changed":
-		args = append(args, "--urgency=critical", "--expire-time=18000")
-		// XFCE sound hint
-		args = append(args, "--hint=string:sound-name:message-new-instant")
-	case "mirror":
-		// Check if it's a call
-		if strings.Contains(strings.ToLower(title), "call") {
-			args = append(args, "--urgency=critical", "--expire-time=25000")
-			args = append(args, "--hint=string:sound-name:phone-incoming-call")
-		} else {
-			args = append(args, "--urgency=normal", "--expire-time=10000")
-		}
-	default:
-		args = append(args, "--urgency=normal", "--expire-time=10000")
+// show displays a notification through the configured backend
+func (m *Manager) show(n *Notification) (uint32, error) {
+	id, err := m.notifier.Show(n)
+	if err != nil {
+		return 0, err
 	}
 
-	// Add category for better desktop integration
-	switch notificationType {
-	case "sms", "sms_changed":
-		args = append(args, "--category=im.received")
-	case "mirror":
-		if strings.Contains(strings.ToLower(title), "call") {
-			args = append(args, "--category=call.incoming")
-		} else {
-			args = append(args, "--category=device")
-		}
-	default:
-		args = append(args, "--category=transfer")
+	if id != 0 {
+		m.registerActions(id, n.Actions)
 	}
+	return id, nil
+}
 
-	// Add icon based on type
-	switch notificationType {
-	case "sms", "sms_changed":
-		args = append(args, "--icon=mail-message-new")
-	case "mirror":
-		if strings.Contains(strings.ToLower(title), "call") {
-			args = append(args, "--icon=call-start")
-		} else {
-			args = append(args, "--icon=phone")
-		}
-	default:
-		args = append(args, "--icon=pushbullet")
-	}
-
-	// Add title and message
-	args = append(args, title, message)
-
-	cmd := exec.Command("notify-send", args...)
-	
-	// Set a timeout for the command
-	done := make(chan error, 1)
-	go func() {
-		done <- cmd.Run()
-	}()
-
-	select {
-	case err := <-done:
-		return err
-	case <-time.After(5 * time.Second):
-		if cmd.Process != nil {
-			cmd.Process.Kill()
+func (m *Manager) registerActions(id uint32, actions []Action) {
+	if len(actions) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.track(id)
+	m.actions[id] = actions
+}
+
+func (m *Manager) handleAction(id uint32, key string) {
+	m.mu.Lock()
+	actions := m.actions[id]
+	m.mu.Unlock()
+
+	for _, action := range actions {
+		if action.Key == key && action.Handler != nil {
+			go action.Handler()
+			return
 		}
-		return fmt.Errorf("notify-send command timed out")
 	}
 }
+
+func (m *Manager) handleClosed(id uint32, reason uint32) {
+	// Only closes by the user are mirrored; expiry and our own closes are not
+	if reason == ClosedDismissed {
+		m.dismissOnPhone(id)
+	}
+
+	m.forgetNotification(id)
+}
+
+// OpenURL opens a URL with the user's preferred application
+func OpenURL(url string) {
+	cmd := exec.Command("xdg-open", url)
+	if err := cmd.Start(); err != nil {
+		log.Printf("Failed to open %s: %v", url, err)
+		return
+	}
+
+	// Reap xdg-open so it does not linger as a zombie
+	go cmd.Wait()
+}
@@ -0,0 +1,323 @@
+package notifications
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"pushbulleter/internal/config"
+	"pushbulleter/internal/pushbullet"
+	"pushbulleter/internal/pushbullet/pbtest"
+)
+
+func newTestManager() (*Manager, *Recorder) {
+	recorder := NewRecorder()
+	return NewManager(recorder, true, true, true, true), recorder
+}
+
+func TestHandlePush(t *testing.T) {
+	m, recorder := newTestManager()
+
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "Hello", Body: "World", Direction: "incoming"})
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "Mine", Direction: "self"})
+
+	shown := recorder.Shown()
+	if len(shown) != 1 {
+		t.Fatalf("shown %d notifications, want 1", len(shown))
+	}
+	if shown[0].Body != "World" {
+		t.Errorf("notification = %+v", shown[0])
+	}
+}
+
+func TestHandlePushTargetedAtDevice(t *testing.T) {
+	m, recorder := newTestManager()
+	m.SetDeviceIden("desktop")
+
+	// Sent from the user's phone, so the direction is "self"
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "To desktop", Direction: "self", TargetDeviceIden: "desktop"})
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "To tablet", Direction: "self", TargetDeviceIden: "tablet"})
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "From friend to tablet", Direction: "incoming", TargetDeviceIden: "tablet"})
+	m.ShowSummary("Missed pushes", []*pushbullet.Push{
+		{Type: "note", Title: "Missed", Direction: "self", TargetDeviceIden: "desktop"},
+		{Type: "note", Title: "Elsewhere", Direction: "incoming", TargetDeviceIden: "tablet"},
+	})
+
+	shown := recorder.Shown()
+	if len(shown) != 2 || shown[0].Title != "To desktop" {
+		t.Fatalf("shown %+v, want the push to this desktop and a summary", shown)
+	}
+	if shown[1].Title != "Missed pushes (1)" || shown[1].Body != "Missed" {
+		t.Errorf("summary = %+v, want only the push to this desktop", shown[1])
+	}
+}
+
+func TestMirrorDismissedOnPhone(t *testing.T) {
+	m, recorder := newTestManager()
+
+	mirror := &pushbullet.Mirror{
+		SourceDeviceIden: "phone",
+		PackageName:      "com.example.chat",
+		ApplicationName:  "Chat",
+		Title:            "Alice",
+		Body:             "Hi",
+		NotificationID:   "7",
+	}
+	m.HandleEphemeral(mirror)
+
+	// An updated mirror replaces the notification instead of adding one
+	updated := *mirror
+	updated.Body = "Hi again"
+	m.HandleEphemeral(&updated)
+
+	if open := recorder.Open(); len(open) != 1 {
+		t.Fatalf("%d notifications open, want 1", len(open))
+	}
+
+	m.HandleEphemeral(&pushbullet.Dismissal{PackageName: "com.example.chat", NotificationID: "7"})
+
+	if open := recorder.Open(); len(open) != 0 {
+		t.Errorf("%d notifications open after dismissal, want 0", len(open))
+	}
+}
+
+func TestTrackingBoundedWithoutCloseSignals(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// The log backend never reports notifications closed
+	m := NewManager(NewLogNotifier(), true, true, true, true)
+
+	for i := 0; i < 3*maxTracked; i++ {
+		m.HandleEphemeral(&pushbullet.Mirror{
+			SourceDeviceIden: "phone",
+			PackageName:      "com.example.chat",
+			ApplicationName:  "Chat",
+			Body:             "message",
+			NotificationID:   pushbullet.FlexibleString(strconv.Itoa(i)),
+			Dismissable:      true,
+		})
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.mirrors) > maxTracked || len(m.mirrorIDs) > maxTracked || len(m.actions) > maxTracked {
+		t.Errorf("tracking %d mirrors, %d mirror ids and %d actions, want at most %d",
+			len(m.mirrors), len(m.mirrorIDs), len(m.actions), maxTracked)
+	}
+	if len(m.mirrors) == 0 {
+		t.Error("newest mirrors are no longer tracked")
+	}
+}
+
+func TestMirrorDismissedOnDesktop(t *testing.T) {
+	server := pbtest.NewServer("o.testkey")
+	defer server.Close()
+
+	client := pushbullet.NewClient("o.testkey", "", server.ClientOptions()...)
+	client.SetUserIden(server.User().Iden)
+
+	m, recorder := newTestManager()
+	m.SetResponder(client)
+
+	m.HandleEphemeral(&pushbullet.Mirror{
+		PackageName:    "com.example.chat",
+		Title:          "Alice",
+		NotificationID: "7",
+		Dismissable:    true,
+	})
+	for id := range recorder.Open() {
+		recorder.Dismiss(id)
+	}
+
+	// The dismissal is sent in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for len(server.Ephemerals()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ephemerals := server.Ephemerals()
+	if len(ephemerals) != 1 {
+		t.Fatalf("server received %d ephemerals, want 1", len(ephemerals))
+	}
+
+	var dismissal struct {
+		Type           string `json:"type"`
+		PackageName    string `json:"package_name"`
+		NotificationID string `json:"notification_id"`
+	}
+	if err := json.Unmarshal(ephemerals[0], &dismissal); err != nil {
+		t.Fatal(err)
+	}
+	if dismissal.Type != "dismissal" || dismissal.PackageName != "com.example.chat" || dismissal.NotificationID != "7" {
+		t.Errorf("ephemeral = %s", ephemerals[0])
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules, err := CompileRules([]config.RuleConfig{
+		{Match: config.RuleMatch{Package: "com.slack*", Title: "^#random"}, Action: "drop"},
+		{Match: config.RuleMatch{Type: "mirror"}, Urgency: "critical", Title: "[{{.AppName}}] {{.Title}}"},
+	})
+	if err != nil {
+		t.Fatalf("CompileRules: %v", err)
+	}
+
+	m, recorder := newTestManager()
+	m.SetRules(rules)
+	m.runHooks = false
+
+	m.HandleEphemeral(&pushbullet.Mirror{PackageName: "com.slack", ApplicationName: "Slack", Title: "#random"})
+	m.HandleEphemeral(&pushbullet.Mirror{PackageName: "com.slack", ApplicationName: "Slack", Title: "#general"})
+
+	shown := recorder.Shown()
+	if len(shown) != 1 {
+		t.Fatalf("shown %d notifications, want 1", len(shown))
+	}
+	if shown[0].Title != "[Slack] #general" || shown[0].Urgency != UrgencyCritical {
+		t.Errorf("notification = %+v", shown[0])
+	}
+}
+
+func TestRulesRespectShowSettings(t *testing.T) {
+	rules, err := CompileRules([]config.RuleConfig{
+		{Match: config.RuleMatch{AppName: "whatsapp"}, Urgency: "critical"},
+		{Match: config.RuleMatch{AppName: "pager"}, Action: "allow"},
+	})
+	if err != nil {
+		t.Fatalf("CompileRules: %v", err)
+	}
+
+	recorder := NewRecorder()
+	m := NewManager(recorder, true, false, true, true)
+	m.SetRules(rules)
+
+	// Only an explicit allow rule shows mirrors while show_mirrors is off
+	m.HandleEphemeral(&pushbullet.Mirror{PackageName: "com.whatsapp", ApplicationName: "WhatsApp", Title: "hidden"})
+	m.HandleEphemeral(&pushbullet.Mirror{PackageName: "com.example.pager", ApplicationName: "Pager", Title: "shown"})
+
+	shown := recorder.Shown()
+	if len(shown) != 1 || shown[0].Title != "Pager: shown" {
+		t.Fatalf("shown %+v, want only the allowed mirror", shown)
+	}
+}
+
+func TestRuleHooksRunForShownNotifications(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hooks")
+	rules, err := CompileRules([]config.RuleConfig{
+		{Match: config.RuleMatch{Type: "note"}, Hook: []string{"sh", "-c", `echo "$PUSHBULLETER_TITLE" >> ` + out}},
+	})
+	if err != nil {
+		t.Fatalf("CompileRules: %v", err)
+	}
+
+	m, recorder := newTestManager()
+	m.SetRules(rules)
+
+	m.ShowSummary("Missed pushes", []*pushbullet.Push{{Type: "note", Title: "missed", Direction: "incoming"}})
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "mine", Direction: "self"})
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "shown", Direction: "incoming"})
+
+	if shown := recorder.Shown(); len(shown) != 2 || shown[1].Title != "shown" {
+		t.Fatalf("shown %+v, want the summary and one push", shown)
+	}
+
+	// Hooks run in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(out)
+		if len(data) > 0 {
+			time.Sleep(100 * time.Millisecond)
+			data, _ = os.ReadFile(out)
+			if string(data) != "shown\n" {
+				t.Errorf("hooks ran for %q, want only the shown push", data)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("hook did not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCompileRulesRejectsInvalidRules(t *testing.T) {
+	for _, cfg := range []config.RuleConfig{
+		{Action: "explode"},
+		{Match: config.RuleMatch{Title: "("}},
+		{Match: config.RuleMatch{Package: "["}},
+		{Urgency: "extreme"},
+		{Match: config.RuleMatch{Time: &config.TimeWindow{Start: "25:00", End: "07:00"}}},
+	} {
+		if _, err := CompileRules([]config.RuleConfig{cfg}); err == nil {
+			t.Errorf("CompileRules(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	// A window that starts and ends at the same time covers the whole day
+	quietHours, err := NewQuietHours(config.QuietHoursConfig{
+		Enabled:  true,
+		Schedule: []config.TimeWindow{{Start: "00:00", End: "00:00"}},
+		Allow:    config.QuietHoursAllow{Packages: []string{"com.example.pager"}},
+	})
+	if err != nil {
+		t.Fatalf("NewQuietHours: %v", err)
+	}
+
+	m, recorder := newTestManager()
+	m.SetQuietHours(quietHours)
+
+	m.HandleEphemeral(&pushbullet.Mirror{PackageName: "com.example.chat", Title: "held"})
+	m.HandleEphemeral(&pushbullet.Mirror{PackageName: "com.example.pager", ApplicationName: "Pager", Title: "urgent"})
+
+	shown := recorder.Shown()
+	if len(shown) != 1 || shown[0].Title != "Pager: urgent" {
+		t.Errorf("shown %+v, want only the allowed pager notification", shown)
+	}
+	if !m.QuietHoursActive() {
+		t.Error("QuietHoursActive = false during quiet hours")
+	}
+
+	// Nothing is flushed while quiet hours last
+	m.FlushQuietQueue()
+	if len(recorder.Shown()) != 1 {
+		t.Error("FlushQuietQueue showed notifications during quiet hours")
+	}
+
+	m.SetQuietHours(nil)
+	m.FlushQuietQueue()
+	shown = recorder.Shown()
+	if len(shown) != 2 || shown[1].Title != "During quiet hours (1)" {
+		t.Errorf("shown %+v, want a quiet hours summary", shown)
+	}
+}
+
+func TestPause(t *testing.T) {
+	m, recorder := newTestManager()
+
+	m.Pause(time.Time{})
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "paused"})
+	if paused, _ := m.Paused(); !paused {
+		t.Error("Paused = false after Pause")
+	}
+
+	m.Resume()
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "resumed"})
+
+	// Pauses run out on their own
+	m.Pause(time.Now().Add(-time.Second))
+	m.HandlePush(&pushbullet.Push{Type: "note", Title: "expired"})
+
+	shown := recorder.Shown()
+	if len(shown) != 2 || shown[0].Title != "resumed" || shown[1].Title != "expired" {
+		t.Errorf("shown %+v, want the pushes sent while not paused", shown)
+	}
+}
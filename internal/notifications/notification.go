@@ -0,0 +1,90 @@
+package notifications
+
+import (
+	"strings"
+	"time"
+)
+
+type Urgency byte
+
+const (
+	UrgencyLow Urgency = iota
+	UrgencyNormal
+	UrgencyCritical
+)
+
+func (u Urgency) String() string {
+	switch u {
+	case UrgencyLow:
+		return "low"
+	case UrgencyCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// Notification describes a desktop notification independently of the
+// backend used to display it.
+type Notification struct {
+	Title     string
+	Body      string
+	Icon      string
+	ImagePath string
+	Category  string
+	SoundName string
+	Urgency   Urgency
+	Timeout   time.Duration
+	Actions   []Action
+}
+
+// Action is a button on a notification. Handler runs when the user invokes
+// the action and OnReply when the user submits an inline reply; backends
+// without action support ignore it.
+type Action struct {
+	Key     string
+	Label   string
+	Handler func()
+	OnReply func(text string)
+}
+
+// newNotification builds a notification with XFCE-optimized settings based on
+// the notification type.
+func newNotification(title, message, notificationType string) *Notification {
+	n := &Notification{
+		Title:    title,
+		Body:     message,
+		Urgency:  UrgencyNormal,
+		Timeout:  10 * time.Second,
+		Category: "transfer",
+		Icon:     "pushbullet",
+	}
+
+	switch notificationType {
+	case "sms", "sms_changed":
+		n.Urgency = UrgencyCritical
+		n.Timeout = 18 * time.Second
+		n.SoundName = "message-new-instant" // XFCE sound hint
+		n.Category = "im.received"
+		n.Icon = "mail-message-new"
+	case "alert":
+		n.Urgency = UrgencyCritical
+		n.Timeout = 0
+		n.Category = "network.error"
+		n.Icon = "dialog-error"
+	case "mirror":
+		// Check if it's a call
+		if strings.Contains(strings.ToLower(title), "call") {
+			n.Urgency = UrgencyCritical
+			n.Timeout = 25 * time.Second
+			n.SoundName = "phone-incoming-call"
+			n.Category = "call.incoming"
+			n.Icon = "call-start"
+		} else {
+			n.Category = "device"
+			n.Icon = "phone"
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,177 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+)
+
+// ErrUnsupported is returned by notifiers that cannot perform an operation,
+// such as closing a notification shown through notify-send.
+var ErrUnsupported = errors.New("operation not supported by notification backend")
+
+// Notifier displays desktop notifications. Ids returned by Show and Replace
+// are backend specific; zero means the backend does not track notifications.
+type Notifier interface {
+	Show(n *Notification) (uint32, error)
+	Replace(id uint32, n *Notification) (uint32, error)
+	Close(id uint32) error
+	Capabilities() []string
+}
+
+// EventHandlers receive user interaction with notifications. Any handler may
+// be nil.
+type EventHandlers struct {
+	OnAction  func(id uint32, key string)
+	OnClosed  func(id uint32, reason uint32)
+	OnReplied func(id uint32, text string)
+}
+
+// EventSource is implemented by notifiers that report user interaction with
+// their notifications.
+type EventSource interface {
+	SetHandlers(handlers EventHandlers)
+}
+
+// NewNotifier creates the notification backend with the given name. The
+// "auto" backend uses D-Bus when a notification server is available and
+// notify-send otherwise.
+func NewNotifier(backend string, execCommand []string) (Notifier, error) {
+	switch backend {
+	case "", "auto":
+		dbusNotifier, err := NewDBusNotifier()
+		if err != nil {
+			log.Printf("D-Bus notifications unavailable, using notify-send: %v", err)
+			return NewNotifySendNotifier(), nil
+		}
+		return newFallbackNotifier(dbusNotifier, NewNotifySendNotifier()), nil
+	case "dbus":
+		dbusNotifier, err := NewDBusNotifier()
+		if err != nil {
+			return nil, err
+		}
+		return dbusNotifier, nil
+	case "notify-send":
+		return NewNotifySendNotifier(), nil
+	case "log":
+		return NewLogNotifier(), nil
+	case "exec":
+		execNotifier, err := NewExecNotifier(execCommand)
+		if err != nil {
+			return nil, err
+		}
+		return execNotifier, nil
+	default:
+		return nil, fmt.Errorf("unknown notification backend %q", backend)
+	}
+}
+
+// fallbackNotifier shows notifications through the primary backend and uses
+// the fallback backend when the primary fails, e.g. because the notification
+// server was restarted. Notifications shown by the fallback are replaced and
+// closed through it as well.
+type fallbackNotifier struct {
+	primary  Notifier
+	fallback Notifier
+
+	mu          sync.Mutex
+	fallbackIDs []uint32
+}
+
+func newFallbackNotifier(primary, fallback Notifier) *fallbackNotifier {
+	return &fallbackNotifier{primary: primary, fallback: fallback}
+}
+
+func (f *fallbackNotifier) Show(n *Notification) (uint32, error) {
+	id, err := f.primary.Show(n)
+	if err == nil {
+		f.setIssuer(id, false)
+		return id, nil
+	}
+
+	log.Printf("Notification backend failed, falling back: %v", err)
+	return f.showFallback(n)
+}
+
+func (f *fallbackNotifier) Replace(id uint32, n *Notification) (uint32, error) {
+	if f.issuedByFallback(id) {
+		newID, err := f.fallback.Replace(id, n)
+		if err == nil {
+			f.setIssuer(id, false)
+			f.setIssuer(newID, true)
+		}
+		return newID, err
+	}
+
+	newID, err := f.primary.Replace(id, n)
+	if err == nil {
+		f.setIssuer(newID, false)
+		return newID, nil
+	}
+
+	log.Printf("Notification backend failed, falling back: %v", err)
+	return f.showFallback(n)
+}
+
+func (f *fallbackNotifier) Close(id uint32) error {
+	if f.issuedByFallback(id) {
+		f.setIssuer(id, false)
+		return f.fallback.Close(id)
+	}
+	return f.primary.Close(id)
+}
+
+func (f *fallbackNotifier) showFallback(n *Notification) (uint32, error) {
+	id, err := f.fallback.Show(n)
+	if err == nil {
+		f.setIssuer(id, true)
+	}
+	return id, err
+}
+
+func (f *fallbackNotifier) issuedByFallback(id uint32) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return id != 0 && slices.Contains(f.fallbackIDs, id)
+}
+
+// setIssuer records whether the fallback backend issued an id, remembering at
+// most maxTracked fallback ids.
+func (f *fallbackNotifier) setIssuer(id uint32, fallback bool) {
+	if id == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.fallbackIDs = slices.DeleteFunc(f.fallbackIDs, func(fallbackID uint32) bool { return fallbackID == id })
+	if fallback {
+		f.fallbackIDs = append(f.fallbackIDs, id)
+		if len(f.fallbackIDs) > maxTracked {
+			f.fallbackIDs = f.fallbackIDs[len(f.fallbackIDs)-maxTracked:]
+		}
+	}
+}
+
+func (f *fallbackNotifier) Capabilities() []string {
+	return f.primary.Capabilities()
+}
+
+func (f *fallbackNotifier) SetHandlers(handlers EventHandlers) {
+	if source, ok := f.primary.(EventSource); ok {
+		source.SetHandlers(handlers)
+	}
+}
+
+func hasCapability(notifier Notifier, capability string) bool {
+	for _, c := range notifier.Capabilities() {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
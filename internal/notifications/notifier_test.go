@@ -0,0 +1,68 @@
+package notifications
+
+import (
+	"errors"
+	"testing"
+)
+
+// brokenNotifier is a Recorder that fails while broken is set, like a D-Bus
+// notifier whose notification server has gone away.
+type brokenNotifier struct {
+	*Recorder
+	broken bool
+}
+
+func (b *brokenNotifier) Show(n *Notification) (uint32, error) {
+	if b.broken {
+		return 0, errors.New("notification server gone")
+	}
+	return b.Recorder.Show(n)
+}
+
+func (b *brokenNotifier) Replace(id uint32, n *Notification) (uint32, error) {
+	if b.broken {
+		return 0, errors.New("notification server gone")
+	}
+	return b.Recorder.Replace(id, n)
+}
+
+func TestFallbackNotifierClosesThroughIssuer(t *testing.T) {
+	primary := &brokenNotifier{Recorder: NewRecorder(), broken: true}
+	fallback := NewRecorder()
+	f := newFallbackNotifier(primary, fallback)
+
+	id, err := f.Show(newNotification("Title", "Body", "note"))
+	if err != nil {
+		t.Fatalf("Show: %v", err)
+	}
+
+	// The primary recovers, but the notification still belongs to the fallback
+	primary.broken = false
+	if _, err := f.Replace(id, newNotification("Title", "Updated", "note")); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if err := f.Close(id); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if shown := fallback.Shown(); len(shown) != 2 || shown[1].Body != "Updated" {
+		t.Errorf("fallback shown %+v, want the notification and its replacement", shown)
+	}
+	if closed := fallback.Closed(); len(closed) != 1 || closed[0] != id {
+		t.Errorf("fallback closed %v, want [%d]", closed, id)
+	}
+	if len(primary.Shown()) != 0 || len(primary.Closed()) != 0 {
+		t.Error("fallback notification replaced or closed through the primary")
+	}
+
+	id, err = f.Show(newNotification("Title", "Body", "note"))
+	if err != nil {
+		t.Fatalf("Show: %v", err)
+	}
+	if err := f.Close(id); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if closed := primary.Closed(); len(closed) != 1 || closed[0] != id {
+		t.Errorf("primary closed %v, want [%d]", closed, id)
+	}
+}
@@ -0,0 +1,69 @@
+package notifications
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeNotifySend puts a notify-send script on PATH that logs its arguments
+// and, like libnotify before 0.7.10, rejects --print-id unless newer is set.
+func fakeNotifySend(t *testing.T, newer bool) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "args")
+
+	help := "Usage: notify-send [OPTION...] <SUMMARY> [BODY]"
+	printID := `echo "Unknown option --print-id" >&2; exit 1`
+	if newer {
+		help += " --print-id"
+		printID = "echo 42"
+	}
+
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = --help ]; then echo '" + help + "'; exit 0; fi\n" +
+		"echo \"$@\" >> " + log + "\n" +
+		"for arg in \"$@\"; do case \"$arg\" in --print-id) " + printID + ";; esac; done\n"
+	if err := os.WriteFile(filepath.Join(dir, "notify-send"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return log
+}
+
+func TestNotifySendWithoutPrintID(t *testing.T) {
+	log := fakeNotifySend(t, false)
+
+	s := NewNotifySendNotifier()
+	if _, err := s.Show(newNotification("Title", "Body", "note")); err != nil {
+		t.Fatalf("Show: %v", err)
+	}
+	if _, err := s.Replace(7, newNotification("Title", "Updated", "note")); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+
+	args, _ := os.ReadFile(log)
+	if strings.Contains(string(args), "-id") {
+		t.Errorf("notify-send called with %q, want no id options", args)
+	}
+}
+
+func TestNotifySendPrintsID(t *testing.T) {
+	log := fakeNotifySend(t, true)
+
+	s := NewNotifySendNotifier()
+	id, err := s.Replace(7, newNotification("Title", "Body", "note"))
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if id != 42 {
+		t.Errorf("id = %d, want 42", id)
+	}
+
+	args, _ := os.ReadFile(log)
+	if !strings.Contains(string(args), "--replace-id=7") {
+		t.Errorf("notify-send called with %q, want --replace-id=7", args)
+	}
+}
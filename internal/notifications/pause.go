@@ -0,0 +1,46 @@
+package notifications
+
+import "time"
+
+// Pause stops showing notifications until the given time. A zero time pauses
+// them until Resume is called.
+func (m *Manager) Pause(until time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.paused = true
+	m.pausedUntil = until
+}
+
+// Resume shows notifications again after Pause.
+func (m *Manager) Resume() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.paused = false
+	m.pausedUntil = time.Time{}
+}
+
+// Paused reports whether notifications are paused and until when; a zero time
+// means until Resume is called.
+func (m *Manager) Paused() (bool, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.paused && !m.pausedUntil.IsZero() && !time.Now().Before(m.pausedUntil) {
+		m.paused = false
+		m.pausedUntil = time.Time{}
+	}
+
+	return m.paused, m.pausedUntil
+}
+
+// active reports whether notifications are enabled and not paused.
+func (m *Manager) active() bool {
+	paused, _ := m.Paused()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.enabled && !paused
+}
@@ -0,0 +1,165 @@
+package notifications
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"pushbulleter/internal/config"
+)
+
+// maxQueuedNotifications bounds the notifications kept during quiet hours;
+// older ones are only counted in the summary.
+const maxQueuedNotifications = 100
+
+// QuietHours is a compiled config.QuietHoursConfig.
+type QuietHours struct {
+	location *time.Location
+	windows  []*timeWindow
+	calls    []string
+	packages []string
+}
+
+// NewQuietHours compiles the quiet hours configuration. It returns nil when
+// quiet hours are disabled.
+func NewQuietHours(cfg config.QuietHoursConfig) (*QuietHours, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	q := &QuietHours{location: time.Local}
+	if cfg.Timezone != "" {
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet hours timezone: %w", err)
+		}
+		q.location = location
+	}
+
+	for i, window := range cfg.Schedule {
+		tw, err := parseTimeWindow(window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quiet hours window %d: %w", i+1, err)
+		}
+		q.windows = append(q.windows, tw)
+	}
+
+	for _, call := range cfg.Allow.Calls {
+		q.calls = append(q.calls, strings.ToLower(call))
+	}
+	for _, pkg := range cfg.Allow.Packages {
+		q.packages = append(q.packages, strings.ToLower(pkg))
+	}
+
+	return q, nil
+}
+
+// Active reports whether t falls inside a quiet hours window.
+func (q *QuietHours) Active(t time.Time) bool {
+	t = t.In(q.location)
+	for _, window := range q.windows {
+		if window.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether a notification is exempt from quiet hours.
+func (q *QuietHours) allows(s ruleSubject) bool {
+	for _, pkg := range q.packages {
+		if strings.ToLower(s.Package) == pkg {
+			return true
+		}
+	}
+
+	if s.Type != "mirror" || !isCallApp(s.Package) {
+		return false
+	}
+
+	for _, caller := range q.calls {
+		if caller == "*" || matchesCaller(caller, s.Title) || matchesCaller(caller, s.Body) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesCaller compares phone numbers by their digits and contact names
+// case-insensitively.
+func matchesCaller(caller, text string) bool {
+	if digits := onlyDigits(caller); len(digits) >= 3 {
+		return strings.Contains(onlyDigits(text), digits)
+	}
+	return strings.Contains(strings.ToLower(text), caller)
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// SetQuietHours replaces the quiet hours schedule; nil disables quiet hours.
+func (m *Manager) SetQuietHours(quietHours *QuietHours) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.quietHours = quietHours
+}
+
+// QuietHoursActive reports whether notifications are currently held back.
+func (m *Manager) QuietHoursActive() bool {
+	m.mu.Lock()
+	quietHours := m.quietHours
+	m.mu.Unlock()
+
+	return quietHours != nil && quietHours.Active(time.Now())
+}
+
+// holdForQuietHours queues the notification and returns true when quiet
+// hours are active and the notification is not exempt.
+func (m *Manager) holdForQuietHours(s ruleSubject, n *Notification) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.quietHours == nil || !m.quietHours.Active(time.Now()) || m.quietHours.allows(s) {
+		return false
+	}
+
+	m.quietCount++
+	if len(m.quietQueue) < maxQueuedNotifications {
+		m.quietQueue = append(m.quietQueue, n)
+	}
+	return true
+}
+
+// FlushQuietQueue shows the notifications held back during quiet hours as a
+// single summary. It does nothing while quiet hours are still active.
+func (m *Manager) FlushQuietQueue() {
+	if m.QuietHoursActive() {
+		return
+	}
+
+	m.mu.Lock()
+	queued, count := m.quietQueue, m.quietCount
+	m.quietQueue, m.quietCount = nil, 0
+	m.mu.Unlock()
+
+	if count == 0 {
+		return
+	}
+
+	lines := make([]string, 0, len(queued))
+	for _, n := range queued {
+		lines = append(lines, summaryLine(n.Title, n.Body))
+	}
+
+	if err := m.showSummaryLines("During quiet hours", lines, count); err != nil {
+		log.Printf("Failed to show quiet hours summary: %v", err)
+	}
+}
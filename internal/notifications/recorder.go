@@ -0,0 +1,133 @@
+package notifications
+
+import "sync"
+
+// Recorder is an in-memory Notifier for tests. It records every notification
+// and lets tests simulate user interaction through Invoke, Dismiss and Reply.
+type Recorder struct {
+	mu            sync.Mutex
+	lastID        uint32
+	notifications map[uint32]*Notification
+	shown         []*Notification
+	closed        []uint32
+	capabilities  []string
+	handlers      EventHandlers
+}
+
+// NewRecorder creates a Recorder that reports the given capabilities.
+// Without capabilities it reports "body" and "actions".
+func NewRecorder(capabilities ...string) *Recorder {
+	if len(capabilities) == 0 {
+		capabilities = []string{"body", "actions"}
+	}
+
+	return &Recorder{
+		notifications: make(map[uint32]*Notification),
+		capabilities:  capabilities,
+	}
+}
+
+func (r *Recorder) Show(n *Notification) (uint32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	r.notifications[r.lastID] = n
+	r.shown = append(r.shown, n)
+	return r.lastID, nil
+}
+
+func (r *Recorder) Replace(id uint32, n *Notification) (uint32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notifications[id] = n
+	r.shown = append(r.shown, n)
+	return id, nil
+}
+
+func (r *Recorder) Close(id uint32) error {
+	r.mu.Lock()
+	onClosed := r.handlers.OnClosed
+	delete(r.notifications, id)
+	r.closed = append(r.closed, id)
+	r.mu.Unlock()
+
+	if onClosed != nil {
+		onClosed(id, ClosedByCall)
+	}
+	return nil
+}
+
+func (r *Recorder) Capabilities() []string {
+	return r.capabilities
+}
+
+func (r *Recorder) SetHandlers(handlers EventHandlers) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers = handlers
+}
+
+// Shown returns every notification shown or replaced so far, in order.
+func (r *Recorder) Shown() []*Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*Notification(nil), r.shown...)
+}
+
+// Closed returns the ids of notifications closed through Close.
+func (r *Recorder) Closed() []uint32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]uint32(nil), r.closed...)
+}
+
+// Open returns the notifications that are currently displayed, keyed by id.
+func (r *Recorder) Open() map[uint32]*Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	open := make(map[uint32]*Notification, len(r.notifications))
+	for id, n := range r.notifications {
+		open[id] = n
+	}
+	return open
+}
+
+// Invoke simulates the user clicking an action on a notification.
+func (r *Recorder) Invoke(id uint32, key string) {
+	r.mu.Lock()
+	onAction := r.handlers.OnAction
+	r.mu.Unlock()
+
+	if onAction != nil {
+		onAction(id, key)
+	}
+}
+
+// Dismiss simulates the user closing a notification.
+func (r *Recorder) Dismiss(id uint32) {
+	r.mu.Lock()
+	onClosed := r.handlers.OnClosed
+	delete(r.notifications, id)
+	r.mu.Unlock()
+
+	if onClosed != nil {
+		onClosed(id, ClosedDismissed)
+	}
+}
+
+// Reply simulates the user typing an inline reply into a notification.
+func (r *Recorder) Reply(id uint32, text string) {
+	r.mu.Lock()
+	onReplied := r.handlers.OnReplied
+	r.mu.Unlock()
+
+	if onReplied != nil {
+		onReplied(id, text)
+	}
+}
@@ -0,0 +1,104 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// inlineReplyKey is the action key notification servers with the
+	// "inline-reply" capability answer with a NotificationReplied signal
+	inlineReplyKey = "inline-reply"
+	replyKey       = "reply"
+)
+
+// replyAction returns a "Reply" action that collects the reply inline when the
+// notification server supports it and through a zenity or yad prompt
+// otherwise. It returns false when replies cannot be sent.
+func (m *Manager) replyAction(prompt string, send func(ctx context.Context, responder Responder, text string) error) (Action, bool) {
+	m.mu.Lock()
+	responder := m.responder
+	m.mu.Unlock()
+
+	if responder == nil || !hasCapability(m.notifier, "actions") {
+		return Action{}, false
+	}
+
+	onReply := func(text string) {
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := send(ctx, responder, text); err != nil {
+			log.Printf("Failed to send reply: %v", err)
+		}
+	}
+
+	if hasCapability(m.notifier, "inline-reply") {
+		return Action{Key: inlineReplyKey, Label: "Reply", OnReply: onReply}, true
+	}
+
+	return Action{
+		Key:   replyKey,
+		Label: "Reply",
+		Handler: func() {
+			text, err := promptReply(prompt)
+			if err != nil {
+				log.Printf("Failed to read reply: %v", err)
+				return
+			}
+			onReply(text)
+		},
+		OnReply: onReply,
+	}, true
+}
+
+func (m *Manager) handleReplied(id uint32, text string) {
+	m.mu.Lock()
+	actions := m.actions[id]
+	m.mu.Unlock()
+
+	for _, action := range actions {
+		if action.OnReply != nil {
+			go action.OnReply(text)
+			return
+		}
+	}
+}
+
+// promptReply asks the user for a reply with zenity or yad. An empty reply
+// means the prompt was cancelled.
+func promptReply(prompt string) (string, error) {
+	// Both tools read the text as Pango markup, but titles come from the phone
+	prompt = html.EscapeString(prompt)
+
+	for _, tool := range []string{"zenity", "yad"} {
+		path, err := exec.LookPath(tool)
+		if err != nil {
+			continue
+		}
+
+		output, err := exec.Command(path, "--entry", "--title=Reply", "--text="+prompt).Output()
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				// Cancelled or closed without replying
+				return "", nil
+			}
+			return "", fmt.Errorf("%s failed: %w", tool, err)
+		}
+
+		return strings.TrimRight(string(output), "\n"), nil
+	}
+
+	return "", fmt.Errorf("no reply prompt available - please install zenity or yad")
+}
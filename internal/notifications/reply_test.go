@@ -0,0 +1,33 @@
+package notifications
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromptReplyEscapesMarkup(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "args")
+
+	// A fake zenity that logs its arguments and replies
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + log + "\necho 'on my way'\n"
+	if err := os.WriteFile(filepath.Join(dir, "zenity"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	text, err := promptReply("Reply to Tom & Jerry <3")
+	if err != nil {
+		t.Fatalf("promptReply: %v", err)
+	}
+	if text != "on my way" {
+		t.Errorf("reply = %q, want %q", text, "on my way")
+	}
+
+	args, _ := os.ReadFile(log)
+	if !strings.Contains(string(args), "--text=Reply to Tom &amp; Jerry &lt;3\n") {
+		t.Errorf("zenity called with %q, want escaped text", args)
+	}
+}
@@ -0,0 +1,310 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"pushbulleter/internal/config"
+	"pushbulleter/internal/pushbullet"
+)
+
+// Rule is a compiled config.RuleConfig.
+type Rule struct {
+	Name  string
+	Drop  bool
+	Allow bool
+
+	typeGlob      string
+	packageGlob   string
+	appNameGlob   string
+	deviceGlob    string
+	emailGlob     string
+	titleRegexp   *regexp.Regexp
+	bodyRegexp    *regexp.Regexp
+	window        *timeWindow
+	urgency       *Urgency
+	timeout       time.Duration
+	icon          string
+	titleTemplate *template.Template
+	hook          []string
+}
+
+// ruleSubject is what rules match against, taken from a push, mirror or SMS.
+// Its fields are also available to title templates, e.g. {{.AppName}}.
+type ruleSubject struct {
+	Type         string
+	Package      string
+	AppName      string
+	Title        string
+	Body         string
+	SourceDevice string
+	SenderEmail  string
+}
+
+func pushSubject(push *pushbullet.Push) ruleSubject {
+	return ruleSubject{
+		Type:         push.Type,
+		Title:        push.Title,
+		Body:         push.Body,
+		SourceDevice: push.SourceDeviceIden,
+		SenderEmail:  push.SenderEmail,
+	}
+}
+
+func mirrorSubject(mirror *pushbullet.Mirror) ruleSubject {
+	return ruleSubject{
+		Type:         "mirror",
+		Package:      mirror.PackageName,
+		AppName:      mirror.ApplicationName,
+		Title:        mirror.Title,
+		Body:         mirror.Body,
+		SourceDevice: mirror.SourceDeviceIden,
+	}
+}
+
+func smsSubject(sms *pushbullet.SMSChanged, notification pushbullet.SMSNotification) ruleSubject {
+	return ruleSubject{
+		Type:         "sms_changed",
+		Title:        notification.Title,
+		Body:         notification.Body,
+		SourceDevice: sms.SourceDeviceIden,
+	}
+}
+
+// SetRules replaces the notification rules. Rules are evaluated in order
+// before the show_* settings; the first matching rule decides whether a
+// notification is dropped or shown, and only an explicit allow rule bypasses
+// the show_* settings.
+func (m *Manager) SetRules(rules []*Rule) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rules = rules
+}
+
+// matchRule returns the first rule matching the subject, or nil.
+func (m *Manager) matchRule(s ruleSubject) *Rule {
+	m.mu.Lock()
+	rules := m.rules
+	m.mu.Unlock()
+
+	now := time.Now()
+	for _, rule := range rules {
+		if rule.matches(s, now) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// runHook runs the hook of the rule, if any, that allowed a notification
+// once it is shown.
+func (m *Manager) runHook(rule *Rule, s ruleSubject) {
+	m.mu.Lock()
+	runHooks := m.runHooks
+	m.mu.Unlock()
+
+	if rule != nil && runHooks {
+		rule.runHook(s)
+	}
+}
+
+// CompileRules validates rule configs and compiles their patterns.
+func CompileRules(cfgs []config.RuleConfig) ([]*Rule, error) {
+	rules := make([]*Rule, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		rule, err := compileRule(name, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notification rule %q: %w", name, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func compileRule(name string, cfg config.RuleConfig) (*Rule, error) {
+	rule := &Rule{
+		Name:        name,
+		typeGlob:    strings.ToLower(cfg.Match.Type),
+		packageGlob: strings.ToLower(cfg.Match.Package),
+		appNameGlob: strings.ToLower(cfg.Match.AppName),
+		deviceGlob:  strings.ToLower(cfg.Match.SourceDevice),
+		emailGlob:   strings.ToLower(cfg.Match.SenderEmail),
+		timeout:     cfg.Timeout,
+		icon:        cfg.Icon,
+		hook:        cfg.Hook,
+	}
+
+	switch strings.ToLower(cfg.Action) {
+	case "":
+	case "allow":
+		rule.Allow = true
+	case "drop":
+		rule.Drop = true
+	default:
+		return nil, fmt.Errorf("unknown action %q", cfg.Action)
+	}
+
+	for _, glob := range []string{rule.typeGlob, rule.packageGlob, rule.appNameGlob, rule.deviceGlob, rule.emailGlob} {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", glob, err)
+		}
+	}
+
+	var err error
+	if cfg.Match.Title != "" {
+		if rule.titleRegexp, err = regexp.Compile(cfg.Match.Title); err != nil {
+			return nil, fmt.Errorf("invalid title pattern: %w", err)
+		}
+	}
+	if cfg.Match.Body != "" {
+		if rule.bodyRegexp, err = regexp.Compile(cfg.Match.Body); err != nil {
+			return nil, fmt.Errorf("invalid body pattern: %w", err)
+		}
+	}
+	if cfg.Match.Time != nil {
+		if rule.window, err = parseTimeWindow(*cfg.Match.Time); err != nil {
+			return nil, fmt.Errorf("invalid time window: %w", err)
+		}
+	}
+
+	if cfg.Urgency != "" {
+		urgency, err := parseUrgency(cfg.Urgency)
+		if err != nil {
+			return nil, err
+		}
+		rule.urgency = &urgency
+	}
+
+	if cfg.Title != "" {
+		if rule.titleTemplate, err = template.New(name).Parse(cfg.Title); err != nil {
+			return nil, fmt.Errorf("invalid title template: %w", err)
+		}
+	}
+
+	return rule, nil
+}
+
+// LegacyFilterRules converts the deprecated substring filters into drop rules
+// on the package and application name.
+func LegacyFilterRules(filters []string) []*Rule {
+	var rules []*Rule
+	for _, filter := range filters {
+		glob := "*" + escapeGlob(strings.ToLower(filter)) + "*"
+		name := fmt.Sprintf("filter %q", filter)
+		rules = append(rules,
+			&Rule{Name: name, Drop: true, typeGlob: "mirror", packageGlob: glob},
+			&Rule{Name: name, Drop: true, typeGlob: "mirror", appNameGlob: glob},
+		)
+	}
+	return rules
+}
+
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func parseUrgency(urgency string) (Urgency, error) {
+	switch strings.ToLower(urgency) {
+	case "low":
+		return UrgencyLow, nil
+	case "normal":
+		return UrgencyNormal, nil
+	case "critical":
+		return UrgencyCritical, nil
+	default:
+		return UrgencyNormal, fmt.Errorf("unknown urgency %q", urgency)
+	}
+}
+
+func matchGlob(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(pattern, strings.ToLower(value))
+	return matched
+}
+
+func (r *Rule) matches(s ruleSubject, now time.Time) bool {
+	return matchGlob(r.typeGlob, s.Type) &&
+		matchGlob(r.packageGlob, s.Package) &&
+		matchGlob(r.appNameGlob, s.AppName) &&
+		matchGlob(r.deviceGlob, s.SourceDevice) &&
+		matchGlob(r.emailGlob, s.SenderEmail) &&
+		(r.titleRegexp == nil || r.titleRegexp.MatchString(s.Title)) &&
+		(r.bodyRegexp == nil || r.bodyRegexp.MatchString(s.Body)) &&
+		(r.window == nil || r.window.contains(now))
+}
+
+// apply overrides notification settings with the rule's settings.
+func (r *Rule) apply(n *Notification, s ruleSubject) {
+	if r.urgency != nil {
+		n.Urgency = *r.urgency
+	}
+	if r.timeout > 0 {
+		n.Timeout = r.timeout
+	}
+	if r.icon != "" {
+		n.Icon = r.icon
+		n.ImagePath = ""
+	}
+	if r.titleTemplate != nil {
+		var title bytes.Buffer
+		if err := r.titleTemplate.Execute(&title, s); err != nil {
+			log.Printf("Failed to rewrite title with rule %q: %v", r.Name, err)
+		} else {
+			n.Title = title.String()
+		}
+	}
+}
+
+// runHook runs the rule's hook command with the subject in its environment.
+func (r *Rule) runHook(s ruleSubject) {
+	if len(r.hook) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, r.hook[0], r.hook[1:]...)
+		cmd.Env = append(os.Environ(),
+			"PUSHBULLETER_RULE="+r.Name,
+			"PUSHBULLETER_TYPE="+s.Type,
+			"PUSHBULLETER_PACKAGE="+s.Package,
+			"PUSHBULLETER_APP_NAME="+s.AppName,
+			"PUSHBULLETER_TITLE="+s.Title,
+			"PUSHBULLETER_BODY="+s.Body,
+			"PUSHBULLETER_SOURCE_DEVICE="+s.SourceDevice,
+			"PUSHBULLETER_SENDER_EMAIL="+s.SenderEmail,
+		)
+
+		if output, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("Hook of rule %q failed: %v: %s", r.Name, err, output)
+		}
+	}()
+}
@@ -0,0 +1,84 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"pushbulleter/internal/config"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// timeWindow is a compiled config.TimeWindow. Times are minutes since
+// midnight; an empty day set means every day.
+type timeWindow struct {
+	days  map[time.Weekday]bool
+	start int
+	end   int
+}
+
+func parseTimeWindow(w config.TimeWindow) (*timeWindow, error) {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time: %w", err)
+	}
+
+	end, err := parseClock(w.End)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end time: %w", err)
+	}
+
+	tw := &timeWindow{start: start, end: end}
+	for _, day := range w.Days {
+		name := strings.ToLower(day)
+		weekday, ok := weekdays[name[:min(3, len(name))]]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q", day)
+		}
+		if tw.days == nil {
+			tw.days = make(map[time.Weekday]bool)
+		}
+		tw.days[weekday] = true
+	}
+
+	return tw, nil
+}
+
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w *timeWindow) onDay(day time.Weekday) bool {
+	return len(w.days) == 0 || w.days[day]
+}
+
+// contains reports whether t falls inside the window. For windows that wrap
+// past midnight the days refer to the day the window starts.
+func (w *timeWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+
+	switch {
+	case w.start == w.end:
+		return w.onDay(t.Weekday())
+	case w.start < w.end:
+		return w.onDay(t.Weekday()) && minute >= w.start && minute < w.end
+	default:
+		if minute >= w.start {
+			return w.onDay(t.Weekday())
+		}
+		return minute < w.end && w.onDay(t.AddDate(0, 0, -1).Weekday())
+	}
+}
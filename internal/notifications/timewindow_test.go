@@ -0,0 +1,47 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	"pushbulleter/internal/config"
+)
+
+func TestTimeWindowContains(t *testing.T) {
+	// 2024-01-05 is a Friday
+	at := func(day int, clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return time.Date(2024, 1, day, parsed.Hour(), parsed.Minute(), 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		window config.TimeWindow
+		t      time.Time
+		want   bool
+	}{
+		{"inside", config.TimeWindow{Start: "09:00", End: "17:00"}, at(5, "12:00"), true},
+		{"at end", config.TimeWindow{Start: "09:00", End: "17:00"}, at(5, "17:00"), false},
+		{"before start", config.TimeWindow{Start: "09:00", End: "17:00"}, at(5, "08:59"), false},
+		{"wraps before midnight", config.TimeWindow{Start: "22:00", End: "07:00"}, at(5, "23:30"), true},
+		{"wraps after midnight", config.TimeWindow{Start: "22:00", End: "07:00"}, at(6, "06:00"), true},
+		{"wraps outside", config.TimeWindow{Start: "22:00", End: "07:00"}, at(5, "12:00"), false},
+		{"weekday", config.TimeWindow{Days: []string{"fri"}, Start: "09:00", End: "17:00"}, at(5, "12:00"), true},
+		{"other weekday", config.TimeWindow{Days: []string{"Monday"}, Start: "09:00", End: "17:00"}, at(5, "12:00"), false},
+		// Saturday morning belongs to the window that started Friday night
+		{"day of start", config.TimeWindow{Days: []string{"fri"}, Start: "22:00", End: "07:00"}, at(6, "06:00"), true},
+		{"whole day", config.TimeWindow{Start: "00:00", End: "00:00"}, at(5, "15:00"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := parseTimeWindow(tt.window)
+			if err != nil {
+				t.Fatalf("parseTimeWindow: %v", err)
+			}
+			if got := window.contains(tt.t); got != tt.want {
+				t.Errorf("contains(%s) = %v, want %v", tt.t.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package pushbullet
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	minReconnectDelay = 2 * time.Second
+	maxReconnectDelay = 5 * time.Minute
+	// stableConnection is how long a connection must last before the
+	// reconnect delay starts over from the minimum
+	stableConnection = time.Minute
+)
+
+// backoff computes exponentially growing reconnect delays with jitter, so
+// that clients do not all retry at the same moment after an outage.
+type backoff struct {
+	min, max time.Duration
+	attempt  int
+}
+
+func newBackoff() *backoff {
+	return &backoff{min: minReconnectDelay, max: maxReconnectDelay}
+}
+
+// next returns the delay before the next attempt, between half and all of
+// min*2^attempt, capped at max.
+func (b *backoff) next() time.Duration {
+	d := b.max
+	if b.attempt < 30 {
+		if exp := b.min << b.attempt; exp < b.max {
+			d = exp
+		}
+	}
+	b.attempt++
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (b *backoff) reset() {
+	b.attempt = 0
+}
@@ -1,12 +1,17 @@
 package pushbullet
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -19,54 +24,78 @@ const (
 
 type Client struct {
 	apiKey     string
+	apiBase    string
+	streamURL  string
 	httpClient *http.Client
+	dialer     *websocket.Dialer
 	e2e        *E2EManager
+	userIden   string
+
+	connMu      sync.Mutex
+	connStates  chan ConnStatus
+	reconnectCh chan struct{}
+	metrics     StreamMetrics
 }
 
 type StreamMessage struct {
-	Type string          `json:"type"`
-	Push json.RawMessage `json:"push,omitempty"`
+	Type    string          `json:"type"`
+	Subtype string          `json:"subtype,omitempty"`
+	Push    json.RawMessage `json:"push,omitempty"`
+
+	// Ephemeral is the decrypted and decoded contents of Push for messages
+	// of type "push"
+	Ephemeral Ephemeral `json:"-"`
 }
 
 type Push struct {
+	Iden                string      `json:"iden,omitempty"`
+	Active              bool        `json:"active,omitempty"`
+	Dismissed           bool        `json:"dismissed,omitempty"`
 	Type                string      `json:"type"`
 	Title               string      `json:"title,omitempty"`
 	Body                string      `json:"body,omitempty"`
+	URL                 string      `json:"url,omitempty"`
+	FileName            string      `json:"file_name,omitempty"`
+	FileType            string      `json:"file_type,omitempty"`
+	FileURL             string      `json:"file_url,omitempty"`
+	ImageURL            string      `json:"image_url,omitempty"`
 	Direction           string      `json:"direction,omitempty"`
+	SenderIden          string      `json:"sender_iden,omitempty"`
 	SenderEmail         string      `json:"sender_email,omitempty"`
 	SenderName          string      `json:"sender_name,omitempty"`
-	ApplicationName     string      `json:"application_name,omitempty"`
-	PackageName         string      `json:"package_name,omitempty"`
-	NotificationID      interface{} `json:"notification_id,omitempty"`
-	NotificationTag     string      `json:"notification_tag,omitempty"`
-	ConversationIden    string      `json:"conversation_iden,omitempty"`
+	ReceiverIden        string      `json:"receiver_iden,omitempty"`
+	ReceiverEmail       string      `json:"receiver_email,omitempty"`
+	TargetDeviceIden    string      `json:"target_device_iden,omitempty"`
+	ChannelIden         string      `json:"channel_iden,omitempty"`
+	ClientIden          string      `json:"client_iden,omitempty"`
 	SourceDeviceIden    string      `json:"source_device_iden,omitempty"`
-	Dismissable         bool        `json:"dismissable,omitempty"`
 	Created             float64     `json:"created,omitempty"`
 	Modified            float64     `json:"modified,omitempty"`
 	
-	// SMS-specific fields
-	Notifications       []SMSNotification `json:"notifications,omitempty"`
-	
 	// Encrypted fields
 	Encrypted  bool   `json:"encrypted,omitempty"`
 	Ciphertext string `json:"ciphertext,omitempty"`
 }
 
-type SMSNotification struct {
-	ThreadID    string  `json:"thread_id,omitempty"`
-	Title       string  `json:"title,omitempty"`
-	Body        string  `json:"body,omitempty"`
-	Timestamp   float64 `json:"timestamp,omitempty"`
-	ImageURL    string  `json:"image_url,omitempty"`
-}
-
-func NewClient(apiKey string, e2eKey string) *Client {
+func NewClient(apiKey string, e2eKey string, opts ...Option) *Client {
 	client := &Client{
-		apiKey: apiKey,
+		apiKey:    apiKey,
+		apiBase:   APIBase,
+		streamURL: WebSocketURL,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		// Honour HTTP_PROXY, HTTPS_PROXY and NO_PROXY for the stream too
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: 45 * time.Second,
+		},
+		connStates:  make(chan ConnStatus, 1),
+		reconnectCh: make(chan struct{}, 1),
+	}
+
+	for _, opt := range opts {
+		opt(client)
 	}
 	
 	if e2eKey != "" {
@@ -82,7 +111,17 @@ func (c *Client) UpdateE2EWithUserIden(e2eKey, userIden string) {
 	}
 }
 
+// SetUserIden records the account's user iden, which is required as the
+// source of ephemerals sent from this client.
+func (c *Client) SetUserIden(userIden string) {
+	c.userIden = userIden
+}
+
+// ConnectStream listens to the realtime event stream until ctx is done,
+// reconnecting with exponential backoff when the connection drops. It stops
+// with ErrUnauthorized when the API key is rejected.
 func (c *Client) ConnectStream(ctx context.Context, messageHandler func(*StreamMessage)) error {
+	b := newBackoff()
 	for {
 		select {
 		case <-ctx.Done():
@@ -90,50 +129,103 @@ func (c *Client) ConnectStream(ctx context.Context, messageHandler func(*StreamM
 		default:
 		}
 
-		if err := c.connectStreamOnce(ctx, messageHandler); err != nil {
-			log.Printf("Stream connection error: %v", err)
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(5 * time.Second):
-				// Retry after 5 seconds
-			}
+		c.setConnState(ConnStatus{State: ConnConnecting})
+
+		connected, err := c.connectStreamOnce(ctx, messageHandler)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrUnauthorized) {
+			log.Printf("Stream connection rejected: %v", err)
+			c.setConnState(ConnStatus{State: ConnAuthFailed, Err: err})
+			return err
+		}
+		if connected >= stableConnection {
+			b.reset()
+		}
+		if err == nil {
+			// Reconnect requested while connected
+			continue
+		}
+
+		delay := b.next()
+		log.Printf("Stream connection error: %v (retrying in %s)", err, delay.Round(time.Second))
+
+		retryAt := time.Now().Add(delay)
+		c.setConnState(ConnStatus{State: ConnBackoff, Err: err, RetryAt: retryAt})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-c.reconnectCh:
+			timer.Stop()
+			log.Println("Reconnecting to Pushbullet stream")
+			b.reset()
+		case <-timer.C:
 		}
 	}
 }
 
-func (c *Client) connectStreamOnce(ctx context.Context, messageHandler func(*StreamMessage)) error {
-	u, err := url.Parse(WebSocketURL + "/" + c.apiKey)
+// connectStreamOnce reads stream messages until the connection drops and
+// reports how long it was connected.
+func (c *Client) connectStreamOnce(ctx context.Context, messageHandler func(*StreamMessage)) (time.Duration, error) {
+	u, err := url.Parse(c.streamURL + "/" + c.apiKey)
 	if err != nil {
-		return fmt.Errorf("failed to parse websocket URL: %w", err)
+		return 0, fmt.Errorf("failed to parse websocket URL: %w", err)
+	}
+
+	// Only reconnect requests made from here on apply to this connection
+	select {
+	case <-c.reconnectCh:
+	default:
 	}
 
-	dialer := websocket.DefaultDialer
-	conn, _, err := dialer.Dial(u.String(), nil)
+	conn, resp, err := c.dialer.DialContext(ctx, u.String(), nil)
 	if err != nil {
-		return fmt.Errorf("failed to connect to websocket: %w", err)
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			err = newAPIError(resp)
+		}
+		return 0, fmt.Errorf("failed to connect to websocket: %w", err)
 	}
 	defer conn.Close()
 
+	connectedAt := time.Now()
 	log.Println("Connected to Pushbullet stream")
+	c.recordConnected(connectedAt)
+	c.setConnState(ConnStatus{State: ConnConnected})
 
-	// Set up ping/pong handling
-	conn.SetPongHandler(func(string) error {
-		return conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	})
-
-	// Start ping ticker
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+	// The server sends a nop every 30 seconds. Drop the connection when they
+	// stop arriving, or when asked to reconnect or shut down, which also
+	// unblocks the reader below.
+	var lastNop atomic.Int64
+	lastNop.Store(connectedAt.UnixNano())
+	var reconnecting, stalled atomic.Bool
 
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
+		ticker := time.NewTicker(heartbeatCheckInterval)
+		defer ticker.Stop()
+
 		for {
 			select {
 			case <-ticker.C:
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				if time.Since(time.Unix(0, lastNop.Load())) > heartbeatTimeout {
+					stalled.Store(true)
+					conn.Close()
 					return
 				}
+			case <-c.reconnectCh:
+				log.Println("Reconnecting to Pushbullet stream")
+				reconnecting.Store(true)
+				conn.Close()
+				return
 			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-done:
 				return
 			}
 		}
@@ -141,16 +233,21 @@ func (c *Client) connectStreamOnce(ctx context.Context, messageHandler func(*Str
 
 	// Read messages
 	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 		_, message, err := conn.ReadMessage()
 		if err != nil {
-			return fmt.Errorf("failed to read message: %w", err)
+			connected := time.Since(connectedAt)
+			switch {
+			case ctx.Err() != nil:
+				err = ctx.Err()
+			case reconnecting.Load():
+				err = nil
+			case stalled.Load():
+				err = fmt.Errorf("no heartbeat from stream for %s", heartbeatTimeout)
+			default:
+				err = fmt.Errorf("failed to read message: %w", err)
+			}
+			c.recordDisconnected(err, stalled.Load())
+			return connected, err
 		}
 
 		var streamMsg StreamMessage
@@ -159,41 +256,66 @@ func (c *Client) connectStreamOnce(ctx context.Context, messageHandler func(*Str
 			continue
 		}
 
-		// Handle encrypted pushes
+		now := time.Now()
+		if streamMsg.Type == "nop" {
+			lastNop.Store(now.UnixNano())
+		}
+		c.recordMessage(streamMsg.Type, now)
+
+		// Decrypt and decode ephemerals once for all handlers
 		if streamMsg.Type == "push" && len(streamMsg.Push) > 0 {
-			var push Push
-			if err := json.Unmarshal(streamMsg.Push, &push); err != nil {
-				log.Printf("Failed to unmarshal push: %v", err)
+			eph, data, err := c.decodeEphemeral(streamMsg.Push)
+			if err != nil {
+				log.Printf("Failed to decode push: %v", err)
 				continue
 			}
-
-			// Decrypt if necessary
-			if push.Encrypted && c.e2e != nil {
-				decrypted, err := c.e2e.Decrypt(push.Ciphertext)
-				if err != nil {
-					log.Printf("Failed to decrypt push: %v", err)
-					continue
-				}
-				
-				if err := json.Unmarshal([]byte(decrypted), &push); err != nil {
-					log.Printf("Failed to unmarshal decrypted push: %v", err)
-					continue
-				}
-			}
-
-			// Re-marshal the potentially decrypted push
-			pushData, _ := json.Marshal(push)
-			streamMsg.Push = pushData
+			streamMsg.Push = data
+			streamMsg.Ephemeral = eph
 		}
 
 		messageHandler(&streamMsg)
 	}
 }
 
-func (c *Client) GetUser(ctx context.Context) (map[string]interface{}, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", APIBase+"/v2/users/me", nil)
+// decryptPush replaces an encrypted push with its decrypted contents. Pushes
+// that are not encrypted, or arrive while E2E is disabled, are left untouched.
+func (c *Client) decryptPush(push *Push) error {
+	if !push.Encrypted || c.e2e == nil {
+		return nil
+	}
+
+	decrypted, err := c.e2e.Decrypt(push.Ciphertext)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(decrypted), push); err != nil {
+		return fmt.Errorf("failed to unmarshal decrypted push: %w", err)
+	}
+
+	return nil
+}
+
+// doRequest performs an authenticated API call. body, if non-nil, is sent as
+// JSON and the response is decoded into out when out is non-nil.
+func (c *Client) doRequest(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	endpoint := c.apiBase + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	req.Header.Set("Access-Token", c.apiKey)
@@ -201,18 +323,21 @@ func (c *Client) GetUser(ctx context.Context) (map[string]interface{}, error) {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d", resp.StatusCode)
+		return newAPIError(resp)
+	}
+
+	if out == nil {
+		return nil
 	}
 
-	var user map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return nil, err
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return user, nil
+	return nil
 }
@@ -0,0 +1,191 @@
+package pushbullet_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"pushbulleter/internal/pushbullet"
+	"pushbulleter/internal/pushbullet/pbtest"
+)
+
+const testAPIKey = "o.testkey"
+
+func newTestClient(t *testing.T, e2eKey string) (*pushbullet.Client, *pbtest.Server) {
+	t.Helper()
+
+	server := pbtest.NewServer(testAPIKey)
+	t.Cleanup(server.Close)
+
+	return pushbullet.NewClient(testAPIKey, e2eKey, server.ClientOptions()...), server
+}
+
+func TestGetUser(t *testing.T) {
+	client, server := newTestClient(t, "")
+
+	user, err := client.GetUser(context.Background())
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if *user != server.User() {
+		t.Errorf("GetUser = %+v, want %+v", user, server.User())
+	}
+}
+
+func TestRejectedAPIKey(t *testing.T) {
+	server := pbtest.NewServer(testAPIKey)
+	defer server.Close()
+
+	client := pushbullet.NewClient("o.wrong", "", server.ClientOptions()...)
+
+	_, err := client.GetUser(context.Background())
+	if !errors.Is(err, pushbullet.ErrUnauthorized) {
+		t.Fatalf("GetUser error = %v, want ErrUnauthorized", err)
+	}
+
+	var apiErr *pushbullet.APIError
+	if !errors.As(err, &apiErr) || apiErr.Type != "invalid_access_token" {
+		t.Errorf("GetUser error = %#v, want invalid_access_token APIError", err)
+	}
+}
+
+func TestPushesSincePaginates(t *testing.T) {
+	client, server := newTestClient(t, "")
+	server.PageSize = 2
+
+	first := server.AddPush(pushbullet.Push{Type: "note", Title: "first"})
+	for i := 0; i < 4; i++ {
+		server.AddPush(pushbullet.Push{Type: "note", Title: "more"})
+	}
+
+	pushes, err := client.PushesSince(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("PushesSince: %v", err)
+	}
+	if len(pushes) != 5 {
+		t.Fatalf("PushesSince returned %d pushes, want 5", len(pushes))
+	}
+
+	pushes, err = client.PushesSince(context.Background(), first.Modified)
+	if err != nil {
+		t.Fatalf("PushesSince: %v", err)
+	}
+	if len(pushes) != 4 {
+		t.Errorf("PushesSince after first push returned %d pushes, want 4", len(pushes))
+	}
+}
+
+func TestCreateAndDismissPush(t *testing.T) {
+	client, server := newTestClient(t, "")
+	ctx := context.Background()
+
+	push, err := client.CreatePush(ctx, pushbullet.NewLink("Go", "", "https://go.dev"))
+	if err != nil {
+		t.Fatalf("CreatePush: %v", err)
+	}
+	if push.Iden == "" || push.Type != "link" || push.URL != "https://go.dev" {
+		t.Errorf("CreatePush = %+v", push)
+	}
+
+	if _, err := client.DismissPush(ctx, push.Iden); err != nil {
+		t.Fatalf("DismissPush: %v", err)
+	}
+	if pushes := server.Pushes(); len(pushes) != 1 || !pushes[0].Dismissed {
+		t.Errorf("server pushes = %+v, want one dismissed push", pushes)
+	}
+
+	if _, err := client.DismissPush(ctx, "missing"); err == nil {
+		t.Error("DismissPush of a missing push succeeded")
+	}
+}
+
+func TestDevices(t *testing.T) {
+	client, server := newTestClient(t, "")
+	ctx := context.Background()
+	phone := server.AddDevice(pushbullet.Device{Nickname: "Phone", HasSMS: true})
+
+	desktop, err := client.CreateDevice(ctx, &pushbullet.DeviceParams{Nickname: "laptop", Icon: "desktop"})
+	if err != nil {
+		t.Fatalf("CreateDevice: %v", err)
+	}
+	if _, err := client.UpdateDevice(ctx, desktop.Iden, &pushbullet.DeviceParams{Nickname: "workstation"}); err != nil {
+		t.Fatalf("UpdateDevice: %v", err)
+	}
+
+	devices, err := client.ListDevices(ctx)
+	if err != nil {
+		t.Fatalf("ListDevices: %v", err)
+	}
+	if len(devices) != 2 || devices[1].Nickname != "workstation" || devices[1].Icon != "desktop" {
+		t.Fatalf("ListDevices = %+v, want phone and renamed desktop", devices)
+	}
+
+	if err := client.DeleteDevice(ctx, phone.Iden); err != nil {
+		t.Fatalf("DeleteDevice: %v", err)
+	}
+	if devices := server.Devices(); len(devices) != 1 || devices[0].Iden != desktop.Iden {
+		t.Errorf("devices after delete = %+v", devices)
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	client, server := newTestClient(t, "")
+	ctx := context.Background()
+
+	upload, err := client.UploadFile(ctx, "build.log", "text/plain", strings.NewReader("all tests passed"))
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if data, ok := server.UploadedFile(upload.FileURL); !ok || string(data) != "all tests passed" {
+		t.Errorf("uploaded %q, want the file contents", data)
+	}
+
+	push, err := client.CreatePush(ctx, pushbullet.NewFile(upload.FileName, upload.FileType, upload.FileURL, ""))
+	if err != nil {
+		t.Fatalf("CreatePush: %v", err)
+	}
+	if push.FileName != "build.log" || push.FileType != "text/plain" || push.FileURL != upload.FileURL {
+		t.Errorf("push = %+v", push)
+	}
+}
+
+func TestPushEphemeralEncrypts(t *testing.T) {
+	client, server := newTestClient(t, "secret")
+	client.SetUserIden(server.User().Iden)
+	client.UpdateE2EWithUserIden("secret", server.User().Iden)
+
+	mirror := &pushbullet.Mirror{PackageName: "com.example", NotificationID: "42"}
+	if err := client.DismissMirror(context.Background(), mirror); err != nil {
+		t.Fatalf("DismissMirror: %v", err)
+	}
+
+	ephemerals := server.Ephemerals()
+	if len(ephemerals) != 1 {
+		t.Fatalf("server received %d ephemerals, want 1", len(ephemerals))
+	}
+
+	var envelope struct {
+		Encrypted  bool   `json:"encrypted"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := json.Unmarshal(ephemerals[0], &envelope); err != nil || !envelope.Encrypted {
+		t.Fatalf("ephemeral %s is not encrypted", ephemerals[0])
+	}
+
+	plaintext, err := pushbullet.NewE2EManagerWithSalt("secret", server.User().Iden).Decrypt(envelope.Ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+
+	eph, err := pushbullet.DecodeEphemeral([]byte(plaintext))
+	if err != nil {
+		t.Fatalf("DecodeEphemeral: %v", err)
+	}
+	dismissal, ok := eph.(*pushbullet.Dismissal)
+	if !ok || dismissal.PackageName != "com.example" || dismissal.NotificationID != "42" ||
+		dismissal.SourceUserIden != server.User().Iden {
+		t.Errorf("ephemeral = %#v, want dismissal of com.example 42", eph)
+	}
+}
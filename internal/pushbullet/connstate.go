@@ -0,0 +1,126 @@
+package pushbullet
+
+import "time"
+
+// ConnState is the state of the realtime event stream connection.
+type ConnState int
+
+const (
+	ConnConnecting ConnState = iota
+	ConnConnected
+	// ConnBackoff means the connection failed and is retried at RetryAt
+	ConnBackoff
+	// ConnAuthFailed means the server rejected the API key
+	ConnAuthFailed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnConnecting:
+		return "connecting"
+	case ConnConnected:
+		return "connected"
+	case ConnBackoff:
+		return "backoff"
+	case ConnAuthFailed:
+		return "auth-failed"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnStatus is a stream connection state change.
+type ConnStatus struct {
+	State   ConnState
+	Err     error
+	RetryAt time.Time
+}
+
+// ConnStates returns a channel of stream connection state changes. Only the
+// latest change is kept for a slow reader.
+func (c *Client) ConnStates() <-chan ConnStatus {
+	return c.connStates
+}
+
+// Reconnect skips the wait before the next connection attempt, or drops the
+// current connection and connects again.
+func (c *Client) Reconnect() {
+	select {
+	case c.reconnectCh <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Client) setConnState(status ConnStatus) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	// Replace a change the reader has not picked up yet
+	select {
+	case <-c.connStates:
+	default:
+	}
+	c.connStates <- status
+}
+
+const (
+	// heartbeatTimeout is how long the stream may go without a nop, which
+	// the server sends every 30 seconds, before it is considered stalled
+	heartbeatTimeout       = 90 * time.Second
+	heartbeatCheckInterval = 5 * time.Second
+)
+
+// StreamMetrics describes the health of the stream connection.
+type StreamMetrics struct {
+	Connected         bool
+	ConnectedSince    time.Time
+	LastHeartbeat     time.Time
+	LastMessage       time.Time
+	Connects          int
+	Disconnects       int
+	HeartbeatTimeouts int
+	Messages          int
+	LastError         string
+}
+
+// StreamMetrics returns a snapshot of the stream connection health.
+func (c *Client) StreamMetrics() StreamMetrics {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	return c.metrics
+}
+
+func (c *Client) recordConnected(at time.Time) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	c.metrics.Connected = true
+	c.metrics.ConnectedSince = at
+	c.metrics.Connects++
+}
+
+func (c *Client) recordDisconnected(err error, stalled bool) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	c.metrics.Connected = false
+	c.metrics.Disconnects++
+	if stalled {
+		c.metrics.HeartbeatTimeouts++
+	}
+	if err != nil {
+		c.metrics.LastError = err.Error()
+	}
+}
+
+func (c *Client) recordMessage(msgType string, at time.Time) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	c.metrics.Messages++
+	c.metrics.LastMessage = at
+	if msgType == "nop" {
+		c.metrics.LastHeartbeat = at
+	}
+}
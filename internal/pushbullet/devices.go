@@ -0,0 +1,87 @@
+package pushbullet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Device is a device registered on the user's account.
+type Device struct {
+	Iden         string  `json:"iden"`
+	Active       bool    `json:"active"`
+	Nickname     string  `json:"nickname,omitempty"`
+	Manufacturer string  `json:"manufacturer,omitempty"`
+	Model        string  `json:"model,omitempty"`
+	AppVersion   int     `json:"app_version,omitempty"`
+	Icon         string  `json:"icon,omitempty"`
+	HasSMS       bool    `json:"has_sms,omitempty"`
+	PushToken    string  `json:"push_token,omitempty"`
+	Created      float64 `json:"created,omitempty"`
+	Modified     float64 `json:"modified,omitempty"`
+}
+
+// DeviceParams holds the fields set when creating or updating a device.
+// Empty fields are left unchanged by UpdateDevice.
+type DeviceParams struct {
+	Nickname     string `json:"nickname,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Model        string `json:"model,omitempty"`
+	AppVersion   int    `json:"app_version,omitempty"`
+	Icon         string `json:"icon,omitempty"`
+	HasSMS       bool   `json:"has_sms,omitempty"`
+	PushToken    string `json:"push_token,omitempty"`
+}
+
+type DeviceList struct {
+	Devices []Device `json:"devices"`
+	Cursor  string   `json:"cursor,omitempty"`
+}
+
+// ListDevices returns the user's active devices, following pagination
+// cursors.
+func (c *Client) ListDevices(ctx context.Context) ([]Device, error) {
+	query := url.Values{"active": {"true"}}
+
+	var devices []Device
+	for {
+		var list DeviceList
+		if err := c.doRequest(ctx, http.MethodGet, "/v2/devices", query, nil, &list); err != nil {
+			return nil, fmt.Errorf("failed to list devices: %w", err)
+		}
+
+		devices = append(devices, list.Devices...)
+
+		if list.Cursor == "" {
+			return devices, nil
+		}
+		query.Set("cursor", list.Cursor)
+	}
+}
+
+func (c *Client) CreateDevice(ctx context.Context, params *DeviceParams) (*Device, error) {
+	var device Device
+	if err := c.doRequest(ctx, http.MethodPost, "/v2/devices", nil, params, &device); err != nil {
+		return nil, fmt.Errorf("failed to create device: %w", err)
+	}
+
+	return &device, nil
+}
+
+func (c *Client) UpdateDevice(ctx context.Context, iden string, params *DeviceParams) (*Device, error) {
+	var device Device
+	if err := c.doRequest(ctx, http.MethodPost, "/v2/devices/"+url.PathEscape(iden), nil, params, &device); err != nil {
+		return nil, fmt.Errorf("failed to update device: %w", err)
+	}
+
+	return &device, nil
+}
+
+func (c *Client) DeleteDevice(ctx context.Context, iden string) error {
+	if err := c.doRequest(ctx, http.MethodDelete, "/v2/devices/"+url.PathEscape(iden), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package pushbullet
+
+import "testing"
+
+func TestE2ERoundTrip(t *testing.T) {
+	e2e := NewE2EManagerWithSalt("password", "ujtestuser")
+
+	ciphertext, err := e2e.Encrypt(`{"type":"mirror"}`)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	plaintext, err := e2e.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if plaintext != `{"type":"mirror"}` {
+		t.Errorf("Decrypt = %q", plaintext)
+	}
+
+	// The user iden salts the key, so another account cannot decrypt it
+	if _, err := NewE2EManagerWithSalt("password", "ujother").Decrypt(ciphertext); err == nil {
+		t.Error("Decrypt with a different salt succeeded")
+	}
+	if _, err := NewE2EManagerWithSalt("wrong", "ujtestuser").Decrypt(ciphertext); err == nil {
+		t.Error("Decrypt with a wrong password succeeded")
+	}
+}
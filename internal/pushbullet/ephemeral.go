@@ -0,0 +1,280 @@
+package pushbullet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// smsPackageName is the package that handles SMS sent through Pushbullet
+const smsPackageName = "com.pushbullet.android"
+
+// Ephemeral is a message delivered inside a stream "push" envelope. The
+// concrete type is one of *Mirror, *Dismissal, *SMSChanged, *Clip,
+// *MessagingExtensionReply or *UnknownEphemeral.
+type Ephemeral interface {
+	EphemeralType() string
+}
+
+// FlexibleString accepts both JSON strings and numbers. Android clients send
+// notification ids as either.
+type FlexibleString string
+
+func (f *FlexibleString) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*f = FlexibleString(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*f = FlexibleString(n)
+	return nil
+}
+
+// Mirror is an Android notification mirrored to the desktop.
+type Mirror struct {
+	SourceDeviceIden string         `json:"source_device_iden,omitempty"`
+	SourceUserIden   string         `json:"source_user_iden,omitempty"`
+	ApplicationName  string         `json:"application_name,omitempty"`
+	PackageName      string         `json:"package_name,omitempty"`
+	Title            string         `json:"title,omitempty"`
+	Body             string         `json:"body,omitempty"`
+	Icon             string         `json:"icon,omitempty"`
+	NotificationID   FlexibleString `json:"notification_id,omitempty"`
+	NotificationTag  string         `json:"notification_tag,omitempty"`
+	ConversationIden string         `json:"conversation_iden,omitempty"`
+	Dismissable      bool           `json:"dismissable,omitempty"`
+	Actions          []MirrorAction `json:"actions,omitempty"`
+	ClientVersion    int            `json:"client_version,omitempty"`
+	HasRoot          bool           `json:"has_root,omitempty"`
+}
+
+type MirrorAction struct {
+	Label      string `json:"label"`
+	TriggerKey string `json:"trigger_key"`
+}
+
+// Dismissal reports that a mirrored notification was dismissed.
+type Dismissal struct {
+	SourceDeviceIden string         `json:"source_device_iden,omitempty"`
+	SourceUserIden   string         `json:"source_user_iden,omitempty"`
+	PackageName      string         `json:"package_name,omitempty"`
+	NotificationID   FlexibleString `json:"notification_id,omitempty"`
+	NotificationTag  string         `json:"notification_tag,omitempty"`
+}
+
+// SMSChanged carries the current SMS notifications of a phone.
+type SMSChanged struct {
+	SourceDeviceIden string            `json:"source_device_iden,omitempty"`
+	Notifications    []SMSNotification `json:"notifications,omitempty"`
+}
+
+type SMSNotification struct {
+	ThreadID  string  `json:"thread_id,omitempty"`
+	Title     string  `json:"title,omitempty"`
+	Body      string  `json:"body,omitempty"`
+	Timestamp float64 `json:"timestamp,omitempty"`
+	ImageURL  string  `json:"image_url,omitempty"`
+}
+
+// Clip is a universal copy & paste clipboard update.
+type Clip struct {
+	SourceDeviceIden string `json:"source_device_iden,omitempty"`
+	SourceUserIden   string `json:"source_user_iden,omitempty"`
+	Body             string `json:"body"`
+}
+
+// MessagingExtensionReply is a reply to a mirrored messaging notification.
+type MessagingExtensionReply struct {
+	PackageName      string `json:"package_name"`
+	SourceUserIden   string `json:"source_user_iden"`
+	TargetDeviceIden string `json:"target_device_iden"`
+	ConversationIden string `json:"conversation_iden"`
+	Message          string `json:"message"`
+}
+
+// UnknownEphemeral holds ephemerals of a type this client does not model.
+type UnknownEphemeral struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (*Mirror) EphemeralType() string                  { return "mirror" }
+func (*Dismissal) EphemeralType() string               { return "dismissal" }
+func (*SMSChanged) EphemeralType() string              { return "sms_changed" }
+func (*Clip) EphemeralType() string                    { return "clip" }
+func (*MessagingExtensionReply) EphemeralType() string { return "messaging_extension_reply" }
+func (u *UnknownEphemeral) EphemeralType() string      { return u.Type }
+
+// DecodeEphemeral decodes an unencrypted ephemeral into its typed form.
+func DecodeEphemeral(data []byte) (Ephemeral, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ephemeral: %w", err)
+	}
+
+	var eph Ephemeral
+	switch header.Type {
+	case "mirror":
+		eph = &Mirror{}
+	case "dismissal":
+		eph = &Dismissal{}
+	case "sms_changed":
+		eph = &SMSChanged{}
+	case "clip":
+		eph = &Clip{}
+	case "messaging_extension_reply":
+		eph = &MessagingExtensionReply{}
+	default:
+		return &UnknownEphemeral{Type: header.Type, Raw: append(json.RawMessage(nil), data...)}, nil
+	}
+
+	if err := json.Unmarshal(data, eph); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s ephemeral: %w", header.Type, err)
+	}
+
+	return eph, nil
+}
+
+// MarshalEphemeral encodes an ephemeral with its "type" field set.
+func MarshalEphemeral(eph Ephemeral) ([]byte, error) {
+	if unknown, ok := eph.(*UnknownEphemeral); ok {
+		return unknown.Raw, nil
+	}
+
+	data, err := json.Marshal(eph)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ephemeral: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to marshal ephemeral: %w", err)
+	}
+	fields["type"], _ = json.Marshal(eph.EphemeralType())
+
+	return json.Marshal(fields)
+}
+
+// PushEphemeral sends an ephemeral to the user's other devices. The payload
+// is encrypted when E2E encryption is enabled.
+func (c *Client) PushEphemeral(ctx context.Context, eph Ephemeral) error {
+	data, err := MarshalEphemeral(eph)
+	if err != nil {
+		return err
+	}
+
+	var push interface{} = json.RawMessage(data)
+	if c.e2e != nil {
+		ciphertext, err := c.e2e.Encrypt(string(data))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt ephemeral: %w", err)
+		}
+		push = map[string]interface{}{
+			"encrypted":  true,
+			"ciphertext": ciphertext,
+		}
+	}
+
+	body := map[string]interface{}{
+		"type": "push",
+		"push": push,
+	}
+
+	if err := c.doRequest(ctx, http.MethodPost, "/v2/ephemerals", nil, body, nil); err != nil {
+		return fmt.Errorf("failed to send %s ephemeral: %w", eph.EphemeralType(), err)
+	}
+
+	return nil
+}
+
+// ReplyToMirror answers a mirrored messaging notification through the app
+// that posted it.
+func (c *Client) ReplyToMirror(ctx context.Context, mirror *Mirror, message string) error {
+	return c.PushEphemeral(ctx, &MessagingExtensionReply{
+		PackageName:      mirror.PackageName,
+		SourceUserIden:   c.sourceUserIden(mirror.SourceUserIden),
+		TargetDeviceIden: mirror.SourceDeviceIden,
+		ConversationIden: mirror.ConversationIden,
+		Message:          message,
+	})
+}
+
+// SendSMS sends a text message from the given phone. conversationIden is the
+// recipient's phone number or an SMS thread id.
+func (c *Client) SendSMS(ctx context.Context, deviceIden, conversationIden, message string) error {
+	if c.userIden == "" {
+		return fmt.Errorf("cannot send SMS before the user iden is known")
+	}
+
+	return c.PushEphemeral(ctx, &MessagingExtensionReply{
+		PackageName:      smsPackageName,
+		SourceUserIden:   c.userIden,
+		TargetDeviceIden: deviceIden,
+		ConversationIden: conversationIden,
+		Message:          message,
+	})
+}
+
+// DismissMirror dismisses a mirrored notification on the device that posted it.
+func (c *Client) DismissMirror(ctx context.Context, mirror *Mirror) error {
+	return c.PushEphemeral(ctx, &Dismissal{
+		SourceUserIden:  c.sourceUserIden(mirror.SourceUserIden),
+		PackageName:     mirror.PackageName,
+		NotificationID:  mirror.NotificationID,
+		NotificationTag: mirror.NotificationTag,
+	})
+}
+
+func (c *Client) sourceUserIden(iden string) string {
+	if iden != "" {
+		return iden
+	}
+	return c.userIden
+}
+
+// decodeEphemeral decrypts the ephemeral if necessary and decodes it. It also
+// returns the plaintext JSON so callers can keep the raw message.
+func (c *Client) decodeEphemeral(data []byte) (Ephemeral, []byte, error) {
+	var envelope struct {
+		Encrypted  bool   `json:"encrypted"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal push: %w", err)
+	}
+
+	if envelope.Encrypted {
+		if c.e2e == nil {
+			return nil, nil, fmt.Errorf("received encrypted push but E2E encryption is not enabled")
+		}
+
+		decrypted, err := c.e2e.Decrypt(envelope.Ciphertext)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt push: %w", err)
+		}
+		data = []byte(decrypted)
+	}
+
+	eph, err := DecodeEphemeral(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return eph, data, nil
+}
@@ -0,0 +1,47 @@
+package pushbullet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// APIError is returned when the Pushbullet API responds with a non-200 status.
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Type       string `json:"type"`
+	Message    string `json:"message"`
+	Cat        string `json:"cat"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("API request failed with status %d", e.StatusCode)
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	// Error bodies look like {"error": {"type": ..., "message": ..., "cat": ...}}
+	var body struct {
+		Error *APIError `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	body.Error = apiErr
+	_ = json.Unmarshal(data, &body)
+
+	return apiErr
+}
+
+// ErrUnauthorized is returned when Pushbullet rejects the API key, for
+// example because it was revoked. Retrying does not help.
+var ErrUnauthorized = errors.New("API key rejected")
+
+// Is makes errors.Is(err, ErrUnauthorized) hold for 401 responses.
+func (e *APIError) Is(target error) bool {
+	return target == ErrUnauthorized && e.StatusCode == http.StatusUnauthorized
+}
@@ -0,0 +1,41 @@
+package pushbullet
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIBase sends REST requests to baseURL instead of APIBase, for example
+// a proxy endpoint or a local test server.
+func WithAPIBase(baseURL string) Option {
+	return func(c *Client) {
+		c.apiBase = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithStreamURL connects to the realtime event stream at streamURL instead of
+// WebSocketURL. The API key is appended as the last path element.
+func WithStreamURL(streamURL string) Option {
+	return func(c *Client) {
+		c.streamURL = strings.TrimRight(streamURL, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for REST requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithDialer sets the dialer used to connect to the event stream.
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(c *Client) {
+		c.dialer = dialer
+	}
+}
@@ -0,0 +1,719 @@
+// Package pbtest provides an in-memory fake of the Pushbullet API and
+// realtime event stream for tests.
+package pbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"pushbulleter/internal/pushbullet"
+)
+
+// Server is a fake Pushbullet server. It implements /v2/users/me,
+// /v2/pushes, /v2/devices, /v2/ephemerals and the websocket stream, and lets
+// tests script stream messages and disconnects.
+type Server struct {
+	// PageSize is the number of pushes per page of /v2/pushes when the
+	// request has no limit
+	PageSize int
+
+	server *httptest.Server
+
+	mu         sync.Mutex
+	apiKey     string
+	user       pushbullet.User
+	pushes     []pushbullet.Push
+	devices    []pushbullet.Device
+	ephemerals []json.RawMessage
+	uploads    map[string][]byte
+	streams    map[*stream]bool
+	lastIden   int
+	lastTime   float64
+
+	connected chan struct{}
+}
+
+type stream struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+// NewServer starts a fake server that accepts apiKey.
+func NewServer(apiKey string) *Server {
+	s := &Server{
+		PageSize:  20,
+		apiKey:    apiKey,
+		streams:   make(map[*stream]bool),
+		uploads:   make(map[string][]byte),
+		connected: make(chan struct{}, 64),
+	}
+	s.user = pushbullet.User{
+		Iden:            "ujtestuser",
+		Email:           "test@example.com",
+		EmailNormalized: "test@example.com",
+		Name:            "Test User",
+		MaxUploadSize:   25 * 1024 * 1024,
+	}
+	s.user.Created = s.now()
+	s.user.Modified = s.user.Created
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/users/me", s.authenticated(s.handleUser))
+	mux.HandleFunc("/v2/pushes", s.authenticated(s.handlePushes))
+	mux.HandleFunc("/v2/pushes/", s.authenticated(s.handlePush))
+	mux.HandleFunc("/v2/devices", s.authenticated(s.handleDevices))
+	mux.HandleFunc("/v2/devices/", s.authenticated(s.handleDevice))
+	mux.HandleFunc("/v2/ephemerals", s.authenticated(s.handleEphemerals))
+	mux.HandleFunc("/v2/upload-request", s.authenticated(s.handleUploadRequest))
+	mux.HandleFunc("/upload/", s.handleUpload)
+	mux.HandleFunc("/files/", s.handleFile)
+	mux.HandleFunc("/websocket/", s.handleStream)
+
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// URL is the base URL of the REST API.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// StreamURL is the URL of the websocket stream, without the API key.
+func (s *Server) StreamURL() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http") + "/websocket"
+}
+
+// ClientOptions points a pushbullet.Client at the fake server.
+func (s *Server) ClientOptions() []pushbullet.Option {
+	return []pushbullet.Option{
+		pushbullet.WithAPIBase(s.URL()),
+		pushbullet.WithStreamURL(s.StreamURL()),
+	}
+}
+
+// Close disconnects all streams and shuts the server down.
+func (s *Server) Close() {
+	s.Disconnect()
+	s.server.Close()
+}
+
+// User returns the account served by /v2/users/me.
+func (s *Server) User() pushbullet.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.user
+}
+
+// SetUser replaces the account served by /v2/users/me.
+func (s *Server) SetUser(user pushbullet.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user = user
+}
+
+// RevokeAPIKey makes the server reject the API key from now on, like a key
+// revoked in the account settings. Open streams are disconnected.
+func (s *Server) RevokeAPIKey() {
+	s.mu.Lock()
+	s.apiKey = ""
+	s.mu.Unlock()
+
+	s.Disconnect()
+}
+
+// AddPush stores a push as if it had been sent from another device, filling
+// in missing idens and timestamps, and tickles connected streams.
+func (s *Server) AddPush(push pushbullet.Push) pushbullet.Push {
+	s.mu.Lock()
+	if push.Iden == "" {
+		push.Iden = s.newIden("push")
+	}
+	if push.Created == 0 {
+		push.Created = s.now()
+	}
+	if push.Modified == 0 {
+		push.Modified = push.Created
+	}
+	if push.Direction == "" {
+		push.Direction = "incoming"
+	}
+	push.Active = true
+	s.pushes = append(s.pushes, push)
+	s.mu.Unlock()
+
+	s.SendTickle("push")
+	return push
+}
+
+// Pushes returns the stored pushes, oldest first.
+func (s *Server) Pushes() []pushbullet.Push {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]pushbullet.Push(nil), s.pushes...)
+}
+
+// AddDevice stores a device, filling in a missing iden and timestamps.
+func (s *Server) AddDevice(device pushbullet.Device) pushbullet.Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if device.Iden == "" {
+		device.Iden = s.newIden("device")
+	}
+	device.Active = true
+	device.Created = s.now()
+	device.Modified = device.Created
+	s.devices = append(s.devices, device)
+	return device
+}
+
+// Devices returns the active devices.
+func (s *Server) Devices() []pushbullet.Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var devices []pushbullet.Device
+	for _, device := range s.devices {
+		if device.Active {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// Ephemerals returns the contents of the ephemerals posted by clients, still
+// encrypted if the client encrypted them. Ephemerals are not forwarded to
+// streams; use SendEphemeral to script them.
+func (s *Server) Ephemerals() []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]json.RawMessage(nil), s.ephemerals...)
+}
+
+// UploadedFile returns the contents of a file uploaded by a client, given its
+// file_url.
+func (s *Server) UploadedFile(fileURL string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.uploads[uploadIden(fileURL)]
+	return data, ok && data != nil
+}
+
+// WaitForStream waits until a client opens a stream connection. Each
+// connection satisfies one call.
+func (s *Server) WaitForStream(timeout time.Duration) error {
+	select {
+	case <-s.connected:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("no stream connection within %s", timeout)
+	}
+}
+
+// SendTickle tells connected streams that data of the given type, such as
+// "push" or "device", changed.
+func (s *Server) SendTickle(subtype string) {
+	s.broadcast(map[string]string{"type": "tickle", "subtype": subtype})
+}
+
+// SendNop sends the heartbeat the real server sends every 30 seconds.
+func (s *Server) SendNop() {
+	s.broadcast(map[string]string{"type": "nop"})
+}
+
+// SendEphemeral sends an ephemeral, such as a mirrored notification, to
+// connected streams.
+func (s *Server) SendEphemeral(eph pushbullet.Ephemeral) error {
+	data, err := pushbullet.MarshalEphemeral(eph)
+	if err != nil {
+		return err
+	}
+
+	s.broadcast(map[string]interface{}{"type": "push", "push": json.RawMessage(data)})
+	return nil
+}
+
+// SendEncryptedEphemeral sends an ephemeral encrypted with the given E2E
+// password, the way phones with end-to-end encryption send them.
+func (s *Server) SendEncryptedEphemeral(eph pushbullet.Ephemeral, password string) error {
+	data, err := pushbullet.MarshalEphemeral(eph)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := pushbullet.NewE2EManagerWithSalt(password, s.User().Iden).Encrypt(string(data))
+	if err != nil {
+		return err
+	}
+
+	s.broadcast(map[string]interface{}{
+		"type": "push",
+		"push": map[string]interface{}{"encrypted": true, "ciphertext": ciphertext},
+	})
+	return nil
+}
+
+// Disconnect drops all stream connections without a close handshake, like a
+// network failure.
+func (s *Server) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for st := range s.streams {
+		st.conn.Close()
+		delete(s.streams, st)
+	}
+}
+
+func (s *Server) broadcast(msg interface{}) {
+	data, _ := json.Marshal(msg)
+
+	s.mu.Lock()
+	streams := make([]*stream, 0, len(s.streams))
+	for st := range s.streams {
+		streams = append(streams, st)
+	}
+	s.mu.Unlock()
+
+	for _, st := range streams {
+		st.mu.Lock()
+		st.conn.WriteMessage(websocket.TextMessage, data)
+		st.mu.Unlock()
+	}
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	apiKey := s.apiKey
+	s.mu.Unlock()
+
+	if apiKey == "" || strings.TrimPrefix(r.URL.Path, "/websocket/") != apiKey {
+		writeError(w, http.StatusUnauthorized, "invalid_access_token", "Access token is missing or invalid.")
+		return
+	}
+
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	st := &stream{conn: conn}
+	s.mu.Lock()
+	s.streams[st] = true
+	s.mu.Unlock()
+
+	select {
+	case s.connected <- struct{}{}:
+	default:
+	}
+
+	// Clients never send anything; read until the connection goes away
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.streams, st)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+func (s *Server) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		apiKey := s.apiKey
+		s.mu.Unlock()
+
+		if apiKey == "" || r.Header.Get("Access-Token") != apiKey {
+			writeError(w, http.StatusUnauthorized, "invalid_access_token", "Access token is missing or invalid.")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed.")
+		return
+	}
+
+	writeJSON(w, s.User())
+}
+
+func (s *Server) handlePushes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listPushes(w, r)
+	case http.MethodPost:
+		s.createPush(w, r)
+	case http.MethodDelete:
+		s.mu.Lock()
+		s.pushes = nil
+		s.mu.Unlock()
+		s.SendTickle("push")
+		writeJSON(w, struct{}{})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed.")
+	}
+}
+
+func (s *Server) listPushes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	modifiedAfter, _ := strconv.ParseFloat(query.Get("modified_after"), 64)
+	activeOnly := query.Get("active") == "true"
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = s.PageSize
+	}
+	offset, _ := strconv.Atoi(query.Get("cursor"))
+
+	s.mu.Lock()
+	var pushes []pushbullet.Push
+	for _, push := range s.pushes {
+		if push.Modified > modifiedAfter && (push.Active || !activeOnly) {
+			pushes = append(pushes, push)
+		}
+	}
+	s.mu.Unlock()
+
+	// Newest first, like the real API
+	sort.SliceStable(pushes, func(i, j int) bool {
+		return pushes[i].Modified > pushes[j].Modified
+	})
+
+	list := pushbullet.PushList{Pushes: []pushbullet.Push{}}
+	if offset < len(pushes) {
+		end := min(offset+limit, len(pushes))
+		list.Pushes = pushes[offset:end]
+		if end < len(pushes) {
+			list.Cursor = strconv.Itoa(end)
+		}
+	}
+
+	writeJSON(w, list)
+}
+
+func (s *Server) createPush(w http.ResponseWriter, r *http.Request) {
+	var req pushbullet.CreatePushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON body.")
+		return
+	}
+
+	switch req.Type {
+	case "note", "link", "file":
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid push type.")
+		return
+	}
+
+	s.mu.Lock()
+	push := pushbullet.Push{
+		Iden:             s.newIden("push"),
+		Active:           true,
+		Type:             req.Type,
+		Title:            req.Title,
+		Body:             req.Body,
+		URL:              req.URL,
+		FileName:         req.FileName,
+		FileType:         req.FileType,
+		FileURL:          req.FileURL,
+		Direction:        "self",
+		SenderIden:       s.user.Iden,
+		SenderEmail:      s.user.Email,
+		SenderName:       s.user.Name,
+		ReceiverIden:     s.user.Iden,
+		ReceiverEmail:    s.user.Email,
+		TargetDeviceIden: req.DeviceIden,
+		ClientIden:       req.ClientIden,
+		SourceDeviceIden: req.SourceDeviceIden,
+	}
+	if req.Email != "" && req.Email != s.user.Email {
+		push.Direction = "outgoing"
+		push.ReceiverIden = ""
+		push.ReceiverEmail = req.Email
+	}
+	if req.ChannelTag != "" {
+		push.Direction = "outgoing"
+		push.ChannelIden = req.ChannelTag
+	}
+	push.Created = s.now()
+	push.Modified = push.Created
+	s.pushes = append(s.pushes, push)
+	s.mu.Unlock()
+
+	s.SendTickle("push")
+	writeJSON(w, push)
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	iden := strings.TrimPrefix(r.URL.Path, "/v2/pushes/")
+
+	s.mu.Lock()
+	index := -1
+	for i := range s.pushes {
+		if s.pushes[i].Iden == iden && s.pushes[i].Active {
+			index = i
+		}
+	}
+	s.mu.Unlock()
+
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "Object not found.")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var update struct {
+			Dismissed *bool `json:"dismissed"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON body.")
+			return
+		}
+
+		s.mu.Lock()
+		if update.Dismissed != nil {
+			s.pushes[index].Dismissed = *update.Dismissed
+		}
+		s.pushes[index].Modified = s.now()
+		push := s.pushes[index]
+		s.mu.Unlock()
+
+		s.SendTickle("push")
+		writeJSON(w, push)
+	case http.MethodDelete:
+		// Deleted pushes stay listed as inactive so clients can sync them
+		s.mu.Lock()
+		s.pushes[index] = pushbullet.Push{
+			Iden:     iden,
+			Created:  s.pushes[index].Created,
+			Modified: s.now(),
+		}
+		s.mu.Unlock()
+
+		s.SendTickle("push")
+		writeJSON(w, struct{}{})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed.")
+	}
+}
+
+func (s *Server) handleDevices(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string][]pushbullet.Device{"devices": append([]pushbullet.Device{}, s.Devices()...)})
+	case http.MethodPost:
+		var device pushbullet.Device
+		if err := json.NewDecoder(r.Body).Decode(&device); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON body.")
+			return
+		}
+		device.Iden = ""
+
+		device = s.AddDevice(device)
+		s.SendTickle("device")
+		writeJSON(w, device)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed.")
+	}
+}
+
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	iden := strings.TrimPrefix(r.URL.Path, "/v2/devices/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := -1
+	for i := range s.devices {
+		if s.devices[i].Iden == iden && s.devices[i].Active {
+			index = i
+		}
+	}
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "Object not found.")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		// Only the fields present in the request are updated
+		data, err := json.Marshal(s.devices[index])
+		if err == nil {
+			var fields map[string]json.RawMessage
+			json.Unmarshal(data, &fields)
+			err = json.NewDecoder(r.Body).Decode(&fields)
+			if err == nil {
+				data, _ = json.Marshal(fields)
+				err = json.Unmarshal(data, &s.devices[index])
+			}
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", "Invalid JSON body.")
+			return
+		}
+
+		s.devices[index].Iden = iden
+		s.devices[index].Modified = s.now()
+		writeJSON(w, s.devices[index])
+	case http.MethodDelete:
+		s.devices[index].Active = false
+		s.devices[index].Modified = s.now()
+		writeJSON(w, struct{}{})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed.")
+	}
+}
+
+func (s *Server) handleEphemerals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed.")
+		return
+	}
+
+	var body struct {
+		Type string          `json:"type"`
+		Push json.RawMessage `json:"push"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Type != "push" || len(body.Push) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Invalid ephemeral.")
+		return
+	}
+
+	s.mu.Lock()
+	s.ephemerals = append(s.ephemerals, body.Push)
+	s.mu.Unlock()
+
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) handleUploadRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed.")
+		return
+	}
+
+	var upload pushbullet.Upload
+	if err := json.NewDecoder(r.Body).Decode(&upload); err != nil || upload.FileName == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "Missing file_name.")
+		return
+	}
+	if upload.FileType == "" {
+		upload.FileType = "application/octet-stream"
+	}
+
+	s.mu.Lock()
+	iden := s.newIden("upload")
+	s.uploads[iden] = nil
+	s.mu.Unlock()
+
+	upload.UploadURL = s.URL() + "/upload/" + iden
+	upload.FileURL = s.URL() + "/files/" + iden + "/" + url.PathEscape(upload.FileName)
+	writeJSON(w, upload)
+}
+
+// handleUpload stores a file posted to an upload URL, like the storage
+// service behind Pushbullet's upload requests.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	iden := strings.TrimPrefix(r.URL.Path, "/upload/")
+
+	s.mu.Lock()
+	data, ok := s.uploads[iden]
+	s.mu.Unlock()
+	if !ok || data != nil || r.Method != http.MethodPost {
+		http.Error(w, "invalid upload", http.StatusForbidden)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err = io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "failed to read file", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.uploads[iden] = data
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	data, ok := s.UploadedFile(r.URL.String())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Write(data)
+}
+
+// uploadIden returns the upload iden from a file URL or path.
+func uploadIden(fileURL string) string {
+	_, path, _ := strings.Cut(fileURL, "/files/")
+	iden, _, _ := strings.Cut(path, "/")
+	return iden
+}
+
+// newIden returns a unique iden; s.mu must be held.
+func (s *Server) newIden(prefix string) string {
+	s.lastIden++
+	return fmt.Sprintf("%s%d", prefix, s.lastIden)
+}
+
+// now returns the current time in API format, strictly increasing so that
+// modified_after filters are deterministic; s.mu must be held.
+func (s *Server) now() float64 {
+	t := float64(time.Now().UnixNano()) / float64(time.Second)
+	if t <= s.lastTime {
+		t = s.lastTime + 0.001
+	}
+	s.lastTime = t
+	return t
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"type":    errType,
+			"message": message,
+			"cat":     "~(=^‥^)",
+		},
+	})
+}
@@ -0,0 +1,154 @@
+package pushbullet
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListPushesOptions controls which pushes ListPushes returns.
+type ListPushesOptions struct {
+	ModifiedAfter float64
+	Active        bool
+	Limit         int
+	Cursor        string
+}
+
+type PushList struct {
+	Pushes []Push `json:"pushes"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// PushTarget selects the recipient of a new push. At most one field should
+// be set; leaving all empty sends the push to all of the user's devices.
+type PushTarget struct {
+	DeviceIden string `json:"device_iden,omitempty"`
+	Email      string `json:"email,omitempty"`
+	ChannelTag string `json:"channel_tag,omitempty"`
+	ClientIden string `json:"client_iden,omitempty"`
+}
+
+type CreatePushRequest struct {
+	PushTarget
+
+	Type             string `json:"type"`
+	Title            string `json:"title,omitempty"`
+	Body             string `json:"body,omitempty"`
+	URL              string `json:"url,omitempty"`
+	FileName         string `json:"file_name,omitempty"`
+	FileType         string `json:"file_type,omitempty"`
+	FileURL          string `json:"file_url,omitempty"`
+	SourceDeviceIden string `json:"source_device_iden,omitempty"`
+	GUID             string `json:"guid,omitempty"`
+}
+
+func NewNote(title, body string) *CreatePushRequest {
+	return &CreatePushRequest{Type: "note", Title: title, Body: body}
+}
+
+func NewLink(title, body, linkURL string) *CreatePushRequest {
+	return &CreatePushRequest{Type: "link", Title: title, Body: body, URL: linkURL}
+}
+
+// NewFile creates a file push for a file that has already been uploaded.
+func NewFile(fileName, fileType, fileURL, body string) *CreatePushRequest {
+	return &CreatePushRequest{
+		Type:     "file",
+		Body:     body,
+		FileName: fileName,
+		FileType: fileType,
+		FileURL:  fileURL,
+	}
+}
+
+func (c *Client) ListPushes(ctx context.Context, opts *ListPushesOptions) (*PushList, error) {
+	query := url.Values{}
+	if opts != nil {
+		if opts.ModifiedAfter > 0 {
+			query.Set("modified_after", strconv.FormatFloat(opts.ModifiedAfter, 'f', -1, 64))
+		}
+		if opts.Active {
+			query.Set("active", "true")
+		}
+		if opts.Limit > 0 {
+			query.Set("limit", strconv.Itoa(opts.Limit))
+		}
+		if opts.Cursor != "" {
+			query.Set("cursor", opts.Cursor)
+		}
+	}
+
+	var list PushList
+	if err := c.doRequest(ctx, http.MethodGet, "/v2/pushes", query, nil, &list); err != nil {
+		return nil, fmt.Errorf("failed to list pushes: %w", err)
+	}
+
+	return &list, nil
+}
+
+// PushesSince returns every push modified after the given timestamp, following
+// pagination cursors and decrypting encrypted pushes.
+func (c *Client) PushesSince(ctx context.Context, modifiedAfter float64) ([]Push, error) {
+	opts := &ListPushesOptions{ModifiedAfter: modifiedAfter}
+
+	var pushes []Push
+	for {
+		list, err := c.ListPushes(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range list.Pushes {
+			if err := c.decryptPush(&list.Pushes[i]); err != nil {
+				log.Printf("Failed to decrypt push %s: %v", list.Pushes[i].Iden, err)
+				continue
+			}
+			pushes = append(pushes, list.Pushes[i])
+		}
+
+		if list.Cursor == "" {
+			return pushes, nil
+		}
+		opts.Cursor = list.Cursor
+	}
+}
+
+func (c *Client) CreatePush(ctx context.Context, req *CreatePushRequest) (*Push, error) {
+	var push Push
+	if err := c.doRequest(ctx, http.MethodPost, "/v2/pushes", nil, req, &push); err != nil {
+		return nil, fmt.Errorf("failed to create push: %w", err)
+	}
+
+	return &push, nil
+}
+
+// DismissPush marks a push as dismissed on all devices.
+func (c *Client) DismissPush(ctx context.Context, iden string) (*Push, error) {
+	body := map[string]bool{"dismissed": true}
+
+	var push Push
+	if err := c.doRequest(ctx, http.MethodPost, "/v2/pushes/"+url.PathEscape(iden), nil, body, &push); err != nil {
+		return nil, fmt.Errorf("failed to dismiss push: %w", err)
+	}
+
+	return &push, nil
+}
+
+func (c *Client) DeletePush(ctx context.Context, iden string) error {
+	if err := c.doRequest(ctx, http.MethodDelete, "/v2/pushes/"+url.PathEscape(iden), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete push: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) DeleteAllPushes(ctx context.Context) error {
+	if err := c.doRequest(ctx, http.MethodDelete, "/v2/pushes", nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete pushes: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package pushbullet_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pushbulleter/internal/pushbullet"
+)
+
+const streamTimeout = 5 * time.Second
+
+// startStream runs ConnectStream in the background and returns the received
+// messages and ConnectStream's result.
+func startStream(t *testing.T, client *pushbullet.Client) (<-chan *pushbullet.StreamMessage, <-chan error) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := make(chan *pushbullet.StreamMessage, 16)
+	result := make(chan error, 1)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		result <- client.ConnectStream(ctx, func(msg *pushbullet.StreamMessage) {
+			messages <- msg
+		})
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(streamTimeout):
+			t.Error("ConnectStream did not return after cancel")
+		}
+	})
+
+	return messages, result
+}
+
+func nextMessage(t *testing.T, messages <-chan *pushbullet.StreamMessage) *pushbullet.StreamMessage {
+	t.Helper()
+
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(streamTimeout):
+		t.Fatal("no stream message received")
+		return nil
+	}
+}
+
+func waitForState(t *testing.T, client *pushbullet.Client, state pushbullet.ConnState) pushbullet.ConnStatus {
+	t.Helper()
+
+	timeout := time.After(streamTimeout)
+	for {
+		select {
+		case status := <-client.ConnStates():
+			if status.State == state {
+				return status
+			}
+		case <-timeout:
+			t.Fatalf("stream never reached state %s", state)
+		}
+	}
+}
+
+func TestConnectStreamDeliversMessages(t *testing.T) {
+	client, server := newTestClient(t, "")
+	messages, _ := startStream(t, client)
+
+	if err := server.WaitForStream(streamTimeout); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, client, pushbullet.ConnConnected)
+
+	server.SendNop()
+	if msg := nextMessage(t, messages); msg.Type != "nop" {
+		t.Errorf("message type = %q, want nop", msg.Type)
+	}
+
+	server.SendTickle("push")
+	if msg := nextMessage(t, messages); msg.Type != "tickle" || msg.Subtype != "push" {
+		t.Errorf("message = %s/%s, want tickle/push", msg.Type, msg.Subtype)
+	}
+
+	server.SendEphemeral(&pushbullet.Mirror{PackageName: "com.example", Title: "Hello"})
+	msg := nextMessage(t, messages)
+	if mirror, ok := msg.Ephemeral.(*pushbullet.Mirror); !ok || mirror.Title != "Hello" {
+		t.Errorf("ephemeral = %#v, want mirror Hello", msg.Ephemeral)
+	}
+
+	metrics := client.StreamMetrics()
+	if !metrics.Connected || metrics.Connects != 1 || metrics.Messages != 3 || metrics.LastHeartbeat.IsZero() {
+		t.Errorf("metrics = %+v", metrics)
+	}
+}
+
+func TestConnectStreamDecryptsMirrors(t *testing.T) {
+	client, server := newTestClient(t, "secret")
+	client.UpdateE2EWithUserIden("secret", server.User().Iden)
+	messages, _ := startStream(t, client)
+
+	if err := server.WaitForStream(streamTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	err := server.SendEncryptedEphemeral(&pushbullet.Mirror{PackageName: "com.example", Body: "secret body"}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := nextMessage(t, messages)
+	if mirror, ok := msg.Ephemeral.(*pushbullet.Mirror); !ok || mirror.Body != "secret body" {
+		t.Errorf("ephemeral = %#v, want decrypted mirror", msg.Ephemeral)
+	}
+}
+
+func TestConnectStreamReconnects(t *testing.T) {
+	client, server := newTestClient(t, "")
+	messages, _ := startStream(t, client)
+
+	if err := server.WaitForStream(streamTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	server.Disconnect()
+	status := waitForState(t, client, pushbullet.ConnBackoff)
+	if status.Err == nil || status.RetryAt.IsZero() {
+		t.Errorf("backoff status = %+v, want error and retry time", status)
+	}
+
+	// Skip the backoff wait
+	client.Reconnect()
+	if err := server.WaitForStream(streamTimeout); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, client, pushbullet.ConnConnected)
+
+	server.SendTickle("device")
+	if msg := nextMessage(t, messages); msg.Subtype != "device" {
+		t.Errorf("message subtype = %q after reconnect, want device", msg.Subtype)
+	}
+
+	if metrics := client.StreamMetrics(); metrics.Connects != 2 || metrics.Disconnects != 1 {
+		t.Errorf("metrics = %+v, want 2 connects and 1 disconnect", metrics)
+	}
+}
+
+func TestConnectStreamStopsOnRevokedKey(t *testing.T) {
+	client, server := newTestClient(t, "")
+	_, result := startStream(t, client)
+
+	if err := server.WaitForStream(streamTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	server.RevokeAPIKey()
+	waitForState(t, client, pushbullet.ConnBackoff)
+	client.Reconnect()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, pushbullet.ErrUnauthorized) {
+			t.Errorf("ConnectStream = %v, want ErrUnauthorized", err)
+		}
+	case <-time.After(streamTimeout):
+		t.Fatal("ConnectStream kept retrying with a revoked key")
+	}
+
+	waitForState(t, client, pushbullet.ConnAuthFailed)
+}
@@ -0,0 +1,76 @@
+package pushbullet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// Upload describes where a file is uploaded to and where it can be
+// downloaded from afterwards.
+type Upload struct {
+	FileName  string `json:"file_name"`
+	FileType  string `json:"file_type"`
+	FileURL   string `json:"file_url"`
+	UploadURL string `json:"upload_url"`
+}
+
+// RequestUpload asks for a URL to upload a file to.
+func (c *Client) RequestUpload(ctx context.Context, fileName, fileType string) (*Upload, error) {
+	body := map[string]string{"file_name": fileName, "file_type": fileType}
+
+	var upload Upload
+	if err := c.doRequest(ctx, http.MethodPost, "/v2/upload-request", nil, body, &upload); err != nil {
+		return nil, fmt.Errorf("failed to request upload: %w", err)
+	}
+
+	return &upload, nil
+}
+
+// UploadFile uploads a file so that it can be sent with NewFile, streaming it
+// from r.
+func (c *Client) UploadFile(ctx context.Context, fileName, fileType string, r io.Reader) (*Upload, error) {
+	upload, err := c.RequestUpload(ctx, fileName, fileType)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		part, err := form.CreateFormFile("file", fileName)
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	// The upload URL is signed, so the request carries no access token
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, upload.UploadURL, pr)
+	if err != nil {
+		pr.Close()
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	// Large files take longer than the client's API timeout
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to upload file: %w", newAPIError(resp))
+	}
+
+	return upload, nil
+}
@@ -0,0 +1,28 @@
+package pushbullet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// User is the Pushbullet account the API key belongs to.
+type User struct {
+	Iden            string  `json:"iden"`
+	Email           string  `json:"email"`
+	EmailNormalized string  `json:"email_normalized,omitempty"`
+	Name            string  `json:"name,omitempty"`
+	ImageURL        string  `json:"image_url,omitempty"`
+	MaxUploadSize   float64 `json:"max_upload_size,omitempty"`
+	Created         float64 `json:"created,omitempty"`
+	Modified        float64 `json:"modified,omitempty"`
+}
+
+func (c *Client) GetUser(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.doRequest(ctx, http.MethodGet, "/v2/users/me", nil, nil, &user); err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,87 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// State holds runtime data that must survive restarts but is not user
+// configuration, such as the push sync cursor.
+type State struct {
+	LastModified float64 `yaml:"last_modified,omitempty"`
+
+	// UserIden is the account's iden from the last successful connection,
+	// which salts the E2E key
+	UserIden string `yaml:"user_iden,omitempty"`
+
+	// DeviceIden is this desktop's device on the account, registered on
+	// first run
+	DeviceIden string `yaml:"device_iden,omitempty"`
+
+	// Paused notifications resume at PausedUntil, or when resumed from the
+	// tray if it is zero
+	Paused      bool      `yaml:"paused,omitempty"`
+	PausedUntil time.Time `yaml:"paused_until,omitempty"`
+}
+
+func Load(statePath string) (*State, error) {
+	if statePath == "" {
+		statePath = getDefaultStatePath()
+	}
+
+	st := &State{}
+
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	return st, nil
+}
+
+func (s *State) Save(statePath string) error {
+	if statePath == "" {
+		statePath = getDefaultStatePath()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, statePath); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+func getDefaultStatePath() string {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		homeDir, _ := os.UserHomeDir()
+		stateHome = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(stateHome, "pushbulleter", "state.yaml")
+}
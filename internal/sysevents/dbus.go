@@ -0,0 +1,131 @@
+package sysevents
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindName      = "org.freedesktop.login1"
+	logindPath      = "/org/freedesktop/login1"
+	logindInterface = "org.freedesktop.login1.Manager"
+
+	networkManagerName      = "org.freedesktop.NetworkManager"
+	networkManagerPath      = "/org/freedesktop/NetworkManager"
+	networkManagerInterface = "org.freedesktop.NetworkManager"
+
+	// nmStateConnectedGlobal is NM_STATE_CONNECTED_GLOBAL, full internet
+	// access
+	nmStateConnectedGlobal uint32 = 70
+)
+
+// DBusWatcher listens to logind for suspend and resume and to
+// NetworkManager for connectivity changes on the system bus.
+type DBusWatcher struct {
+	conn   *dbus.Conn
+	events chan Event
+	online bool
+}
+
+// NewDBusWatcher connects to the system bus and subscribes to logind and
+// NetworkManager signals. Either service may be missing.
+func NewDBusWatcher() (*DBusWatcher, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+
+	subscriptions := []struct{ path, iface, member string }{
+		{logindPath, logindInterface, "PrepareForSleep"},
+		{networkManagerPath, networkManagerInterface, "StateChanged"},
+	}
+	for _, s := range subscriptions {
+		if err := conn.AddMatchSignal(
+			dbus.WithMatchObjectPath(dbus.ObjectPath(s.path)),
+			dbus.WithMatchInterface(s.iface),
+			dbus.WithMatchMember(s.member),
+		); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to subscribe to %s: %w", s.member, err)
+		}
+	}
+
+	w := &DBusWatcher{
+		conn:   conn,
+		events: make(chan Event, 8),
+		online: true,
+	}
+
+	// Without NetworkManager the network is assumed to be up
+	nm := conn.Object(networkManagerName, networkManagerPath)
+	if state, err := nm.GetProperty(networkManagerInterface + ".State"); err == nil {
+		if s, ok := state.Value().(uint32); ok {
+			w.online = s >= nmStateConnectedGlobal
+		}
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go w.listen(signals)
+
+	return w, nil
+}
+
+func (w *DBusWatcher) Events() <-chan Event {
+	return w.events
+}
+
+func (w *DBusWatcher) Close() error {
+	return w.conn.Close()
+}
+
+func (w *DBusWatcher) listen(signals <-chan *dbus.Signal) {
+	defer close(w.events)
+
+	for signal := range signals {
+		if len(signal.Body) < 1 {
+			continue
+		}
+
+		switch signal.Name {
+		case logindInterface + ".PrepareForSleep":
+			if sleeping, ok := signal.Body[0].(bool); ok {
+				if sleeping {
+					w.send(Suspend)
+				} else {
+					w.send(Resume)
+				}
+			}
+		case networkManagerInterface + ".StateChanged":
+			state, ok := signal.Body[0].(uint32)
+			if !ok {
+				continue
+			}
+
+			// Only report transitions, NetworkManager passes through several
+			// connecting states
+			online := state >= nmStateConnectedGlobal
+			if online == w.online {
+				continue
+			}
+			w.online = online
+
+			if online {
+				w.send(NetworkUp)
+			} else {
+				w.send(NetworkDown)
+			}
+		}
+	}
+}
+
+// send delivers an event without blocking the D-Bus connection.
+func (w *DBusWatcher) send(event Event) {
+	select {
+	case w.events <- event:
+	default:
+		log.Printf("Dropped system event: %s", event)
+	}
+}
@@ -0,0 +1,34 @@
+// Package sysevents reports system events that affect the Pushbullet
+// connection, such as resuming from suspend or the network coming up.
+package sysevents
+
+// Event is a system event.
+type Event int
+
+const (
+	Suspend Event = iota
+	Resume
+	NetworkUp
+	NetworkDown
+)
+
+func (e Event) String() string {
+	switch e {
+	case Suspend:
+		return "suspend"
+	case Resume:
+		return "resume"
+	case NetworkUp:
+		return "network up"
+	case NetworkDown:
+		return "network down"
+	default:
+		return "unknown"
+	}
+}
+
+// Watcher delivers system events.
+type Watcher interface {
+	Events() <-chan Event
+	Close() error
+}
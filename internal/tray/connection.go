@@ -0,0 +1,114 @@
+package tray
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"sync"
+
+	"fyne.io/systray"
+)
+
+var (
+	offlineIconOnce sync.Once
+	offlineIconData []byte
+)
+
+// SetConnection shows whether the Pushbullet stream is connected. status
+// describes the state, such as "connected" or "offline, retrying at 10:04".
+func (t *TrayManager) SetConnection(online bool, status string) {
+	t.mu.Lock()
+	t.online = online
+	t.connStatus = status
+	t.mu.Unlock()
+
+	t.refresh()
+}
+
+// OnReconnect sets the function called by the "Reconnect now" menu item.
+func (t *TrayManager) OnReconnect(reconnect func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onReconnect = reconnect
+}
+
+func (t *TrayManager) setupConnection() {
+	mStatus := systray.AddMenuItem("Connecting...", "Pushbullet connection")
+	mStatus.Disable()
+	mReconnect := systray.AddMenuItem("Reconnect now", "Connect to Pushbullet again")
+
+	go func() {
+		for {
+			select {
+			case <-mReconnect.ClickedCh:
+				t.mu.Lock()
+				reconnect := t.onReconnect
+				t.mu.Unlock()
+
+				if reconnect != nil {
+					reconnect()
+				}
+			case <-t.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	t.mu.Lock()
+	t.mStatus = mStatus
+	t.mu.Unlock()
+}
+
+// refreshConnection updates the icon and status item; t.mu must be held.
+func (t *TrayManager) refreshConnection() {
+	if t.online != t.iconOnline {
+		t.iconOnline = t.online
+		if t.online {
+			systray.SetIcon(iconData)
+		} else {
+			systray.SetIcon(offlineIcon())
+		}
+	}
+
+	status := t.connStatus
+	if status == "" {
+		status = "connecting..."
+	}
+	t.mStatus.SetTitle("Status: " + status)
+}
+
+// offlineIcon returns a greyed out copy of the tray icon.
+func offlineIcon() []byte {
+	offlineIconOnce.Do(func() {
+		offlineIconData = iconData
+
+		src, err := png.Decode(bytes.NewReader(iconData))
+		if err != nil {
+			log.Printf("Failed to decode tray icon: %v", err)
+			return
+		}
+
+		bounds := src.Bounds()
+		grey := image.NewNRGBA(bounds)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+				luma := color.GrayModel.Convert(color.NRGBA{R: c.R, G: c.G, B: c.B, A: 255}).(color.Gray)
+				// Keep the shape but fade it out
+				grey.SetNRGBA(x, y, color.NRGBA{R: luma.Y, G: luma.Y, B: luma.Y, A: c.A / 2})
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, grey); err != nil {
+			log.Printf("Failed to encode offline tray icon: %v", err)
+			return
+		}
+		offlineIconData = buf.Bytes()
+	})
+
+	return offlineIconData
+}
@@ -0,0 +1,119 @@
+package tray
+
+import (
+	"time"
+
+	"fyne.io/systray"
+)
+
+// OnPause sets the function called by the pause menu items with the time
+// notifications resume, or a zero time to pause them indefinitely.
+func (t *TrayManager) OnPause(pause func(until time.Time)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onPause = pause
+}
+
+// OnResume sets the function called by the "Resume" menu item.
+func (t *TrayManager) OnResume(resume func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onResume = resume
+}
+
+// SetPaused shows whether notifications are paused and until when; a zero
+// time means indefinitely.
+func (t *TrayManager) SetPaused(paused bool, until time.Time) {
+	t.mu.Lock()
+	t.paused = paused
+	t.pausedUntil = until
+	t.mu.Unlock()
+
+	t.refresh()
+}
+
+func (t *TrayManager) setupPause() {
+	mPause := systray.AddMenuItem("Pause notifications", "Stop showing notifications for a while")
+	mPause30 := mPause.AddSubMenuItem("For 30 minutes", "")
+	mPause1h := mPause.AddSubMenuItem("For 1 hour", "")
+	mPauseTomorrow := mPause.AddSubMenuItem("Until tomorrow", "Until 8:00")
+	mPauseForever := mPause.AddSubMenuItem("Indefinitely", "Until resumed")
+	mResume := systray.AddMenuItem("Resume notifications", "Show notifications again")
+	mResume.Hide()
+
+	go func() {
+		for {
+			var until time.Time
+			select {
+			case <-mPause30.ClickedCh:
+				until = time.Now().Add(30 * time.Minute)
+			case <-mPause1h.ClickedCh:
+				until = time.Now().Add(time.Hour)
+			case <-mPauseTomorrow.ClickedCh:
+				until = nextMorning(time.Now())
+			case <-mPauseForever.ClickedCh:
+			case <-mResume.ClickedCh:
+				t.mu.Lock()
+				resume := t.onResume
+				t.mu.Unlock()
+
+				if resume != nil {
+					resume()
+				}
+				continue
+			case <-t.ctx.Done():
+				return
+			}
+
+			t.mu.Lock()
+			pause := t.onPause
+			t.mu.Unlock()
+
+			if pause != nil {
+				pause(until)
+			}
+		}
+	}()
+
+	t.mu.Lock()
+	t.mResume = mResume
+	t.mu.Unlock()
+}
+
+// nextMorning returns the next 8:00 after now: today's if it is still to
+// come, otherwise tomorrow's.
+func nextMorning(now time.Time) time.Time {
+	morning := time.Date(now.Year(), now.Month(), now.Day(), 8, 0, 0, 0, now.Location())
+	if !morning.After(now) {
+		morning = morning.AddDate(0, 0, 1)
+	}
+	return morning
+}
+
+// refreshPause updates the Resume item; t.mu must be held.
+func (t *TrayManager) refreshPause() {
+	if !t.paused {
+		t.mResume.Hide()
+		return
+	}
+
+	if t.pausedUntil.IsZero() {
+		t.mResume.SetTitle("Resume notifications")
+	} else {
+		t.mResume.SetTitle("Resume notifications (paused until " + t.pausedUntil.Format("15:04") + ")")
+	}
+	t.mResume.Show()
+}
+
+// pauseStatus describes the pause for the tooltip; t.mu must be held.
+func (t *TrayManager) pauseStatus() string {
+	if !t.paused {
+		return ""
+	}
+	if t.pausedUntil.IsZero() {
+		return "paused"
+	}
+	return "paused until " + t.pausedUntil.Format("15:04")
+}
@@ -0,0 +1,26 @@
+package tray
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextMorning(t *testing.T) {
+	for _, tt := range []struct {
+		now, want string
+	}{
+		{"2024-03-10 00:30", "2024-03-10 08:00"},
+		{"2024-03-10 07:59", "2024-03-10 08:00"},
+		{"2024-03-10 08:00", "2024-03-11 08:00"},
+		{"2024-03-10 22:15", "2024-03-11 08:00"},
+		{"2024-12-31 23:00", "2025-01-01 08:00"},
+	} {
+		now, err := time.ParseInLocation("2006-01-02 15:04", tt.now, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := nextMorning(now).Format("2006-01-02 15:04"); got != tt.want {
+			t.Errorf("nextMorning(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package tray
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"fyne.io/systray"
+
+	"pushbulleter/internal/history"
+	"pushbulleter/internal/notifications"
+)
+
+// RecentLimit is the number of events listed in the Recent submenu.
+const RecentLimit = 10
+
+const maxRecentTitle = 40
+
+// SetRecent replaces the Recent submenu, e.g. with events from the history.
+// Only the newest RecentLimit events are kept.
+func (t *TrayManager) SetRecent(events []history.Event) {
+	if len(events) > RecentLimit {
+		events = events[len(events)-RecentLimit:]
+	}
+
+	t.mu.Lock()
+	t.recent = append([]history.Event(nil), events...)
+	t.mu.Unlock()
+
+	t.refresh()
+}
+
+// AddRecent adds an event to the top of the Recent submenu.
+func (t *TrayManager) AddRecent(event history.Event) {
+	t.mu.Lock()
+	t.recent = append(t.recent, event)
+	if len(t.recent) > RecentLimit {
+		t.recent = t.recent[len(t.recent)-RecentLimit:]
+	}
+	t.mu.Unlock()
+
+	t.refresh()
+}
+
+// ClearRecent empties the Recent submenu.
+func (t *TrayManager) ClearRecent() {
+	t.mu.Lock()
+	t.recent = nil
+	t.mu.Unlock()
+
+	t.refresh()
+}
+
+// setupRecent adds the Recent submenu with a fixed set of items that are
+// shown as events arrive, since menu items cannot be removed.
+func (t *TrayManager) setupRecent() {
+	mRecent := systray.AddMenuItem("Recent", "Recent pushes and notifications")
+	mEmpty := mRecent.AddSubMenuItem("No recent events", "")
+	mEmpty.Disable()
+
+	items := make([]*systray.MenuItem, RecentLimit)
+	for i := range items {
+		items[i] = mRecent.AddSubMenuItem("", "")
+		items[i].Hide()
+		go t.handleRecentClicks(items[i], i)
+	}
+
+	mClear := mRecent.AddSubMenuItem("Clear", "Clear the list")
+	go func() {
+		for {
+			select {
+			case <-mClear.ClickedCh:
+				t.ClearRecent()
+			case <-t.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	t.mu.Lock()
+	t.mRecentEmpty = mEmpty
+	t.mRecentItems = items
+	t.mRecentClear = mClear
+	t.mu.Unlock()
+}
+
+// refreshRecent updates the Recent submenu; t.mu must be held.
+func (t *TrayManager) refreshRecent() {
+	now := time.Now()
+	for i, item := range t.mRecentItems {
+		// Newest first
+		if i >= len(t.recent) {
+			item.Hide()
+			continue
+		}
+		event := t.recent[len(t.recent)-1-i]
+
+		item.SetTitle(fmt.Sprintf("%s (%s)", recentTitle(event), relativeTime(event.Timestamp, now)))
+		if event.URL != "" {
+			item.SetTooltip("Open " + event.URL)
+		} else {
+			item.SetTooltip("Copy to clipboard")
+		}
+		item.Show()
+	}
+
+	if len(t.recent) == 0 {
+		t.mRecentEmpty.Show()
+		t.mRecentClear.Hide()
+	} else {
+		t.mRecentEmpty.Hide()
+		t.mRecentClear.Show()
+	}
+}
+
+func (t *TrayManager) handleRecentClicks(item *systray.MenuItem, index int) {
+	for {
+		select {
+		case <-item.ClickedCh:
+			t.mu.Lock()
+			var event history.Event
+			ok := index < len(t.recent)
+			if ok {
+				event = t.recent[len(t.recent)-1-index]
+			}
+			t.mu.Unlock()
+
+			if ok {
+				openRecent(event)
+			}
+		case <-t.ctx.Done():
+			return
+		}
+	}
+}
+
+// openRecent opens an event's link, or copies its body to the clipboard
+func openRecent(event history.Event) {
+	if event.URL != "" {
+		notifications.OpenURL(event.URL)
+		return
+	}
+
+	text := event.Message
+	if text == "" {
+		text = event.Title
+	}
+	if err := copyToClipboard(text); err != nil {
+		log.Printf("Failed to copy to clipboard: %v", err)
+	}
+}
+
+// copyToClipboard uses whichever of wl-copy, xclip and xsel is installed
+func copyToClipboard(text string) error {
+	commands := [][]string{
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append([][]string{{"wl-copy"}}, commands...)
+	}
+
+	for _, command := range commands {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+
+	return fmt.Errorf("no clipboard tool found - please install xclip, xsel or wl-clipboard")
+}
+
+func recentTitle(event history.Event) string {
+	title := event.Title
+	if title == "" {
+		title = event.Message
+	}
+	if event.App != "" {
+		title = event.App + ": " + title
+	}
+
+	title = strings.Join(strings.Fields(title), " ")
+	if utf8.RuneCountInString(title) > maxRecentTitle {
+		title = string([]rune(title)[:maxRecentTitle-1]) + "…"
+	}
+	return title
+}
+
+func relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	}
+}
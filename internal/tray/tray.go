@@ -4,8 +4,12 @@ import (
 	"context"
 	_ "embed"
 	"log"
+	"sync"
+	"time"
 
 	"fyne.io/systray"
+
+	"pushbulleter/internal/history"
 )
 
 //go:embed tray_icon.png
@@ -14,6 +18,26 @@ var iconData []byte
 type TrayManager struct {
 	ctx    context.Context
 	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	ready       bool
+	online      bool
+	iconOnline  bool
+	connStatus  string
+	onReconnect func()
+	onPause     func(until time.Time)
+	onResume    func()
+	paused      bool
+	pausedUntil time.Time
+	dnd         bool
+	recent      []history.Event
+
+	mStatus      *systray.MenuItem
+	mResume      *systray.MenuItem
+	mDND         *systray.MenuItem
+	mRecentEmpty *systray.MenuItem
+	mRecentItems []*systray.MenuItem
+	mRecentClear *systray.MenuItem
 }
 
 func NewTrayManager() *TrayManager {
@@ -38,18 +62,37 @@ func (t *TrayManager) Run(onReady func(), onExit func()) {
 }
 
 func (t *TrayManager) setupTray() {
-	// Set icon
-	systray.SetIcon(iconData)
+	// Set icon, greyed out until the stream connects
+	systray.SetIcon(offlineIcon())
 	systray.SetTitle("pushbulleter")
 	systray.SetTooltip("pushbulleter")
 
 	// Add menu items
+	t.setupConnection()
+	systray.AddSeparator()
+	t.setupPause()
+	mDND := systray.AddMenuItem("Do not disturb", "Quiet hours are active")
+	mDND.Disable()
+	t.setupRecent()
+	systray.AddSeparator()
 	mQuit := systray.AddMenuItem("Quit", "Quit the application")
 
-	// Handle menu clicks
+	t.mu.Lock()
+	t.mDND = mDND
+	t.ready = true
+	t.mu.Unlock()
+	t.refresh()
+
+	// Handle menu clicks and keep relative times current
 	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
 		for {
 			select {
+			case <-ticker.C:
+				t.refresh()
+
 			case <-mQuit.ClickedCh:
 				log.Println("Quit clicked")
 				t.cancel()
@@ -64,6 +107,44 @@ func (t *TrayManager) setupTray() {
 	}()
 }
 
+// SetDND shows whether quiet hours are holding notifications back.
+func (t *TrayManager) SetDND(active bool) {
+	t.mu.Lock()
+	t.dnd = active
+	t.mu.Unlock()
+
+	t.refresh()
+}
+
+// refresh updates the tooltip and status items once the tray is ready.
+func (t *TrayManager) refresh() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.ready {
+		return
+	}
+
+	tooltip := "pushbulleter"
+	if t.connStatus != "" {
+		tooltip += " - " + t.connStatus
+	}
+	if status := t.pauseStatus(); status != "" {
+		tooltip += " (" + status + ")"
+	}
+	if t.dnd {
+		tooltip += " (do not disturb)"
+		t.mDND.Show()
+	} else {
+		t.mDND.Hide()
+	}
+	systray.SetTooltip(tooltip)
+
+	t.refreshConnection()
+	t.refreshPause()
+	t.refreshRecent()
+}
+
 func (t *TrayManager) Stop() {
 	t.cancel()
 }